@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/mailer"
 	"github.com/clcert/osr/models"
@@ -8,8 +10,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var printDDL bool
+
 func init() {
 	ModelsCmd.AddCommand(CreateDBCommand)
+	ModelsCmd.AddCommand(ClickhouseCmd)
+	ClickhouseCmd.AddCommand(ClickhouseCreateDBCommand)
+	ClickhouseCreateDBCommand.Flags().BoolVarP(&printDDL, "print", "p", false, "Print the statements instead of executing them.")
 }
 
 // Models command groups all importer related to models.
@@ -36,3 +43,41 @@ var CreateDBCommand = &cobra.Command{
 
 	},
 }
+
+// ClickhouseCmd groups the commands related to the models stored in ClickHouse.
+var ClickhouseCmd = &cobra.Command{
+	Use:   "clickhouse",
+	Short: "Manages the models stored in ClickHouse",
+	Long:  "Manages the models stored in ClickHouse",
+}
+
+// ClickhouseCreateDBCommand creates the ClickHouse tables of the models, using MergeTree tables derived from their structs.
+var ClickhouseCreateDBCommand = &cobra.Command{
+	Use:   "createdb",
+	Short: "Creates the ClickHouse tables",
+	Long:  "Creates the ClickHouse tables of the models which can be stored in ClickHouse",
+	Run: func(cmd *cobra.Command, args []string) {
+		if printDDL {
+			for _, model := range models.DefaultModels.ClickhouseModels() {
+				ddl, err := model.ClickhouseDDL()
+				if err != nil {
+					panic(&panics.Info{
+						Text:        "couldn't derive the clickhouse table of a model",
+						Err:         err,
+						Attachments: []mailer.Attachable{logs.Log},
+					})
+				}
+				fmt.Printf("%s;\n\n", ddl)
+			}
+			return
+		}
+		err := models.DefaultModels.CreateClickhouseTables()
+		if err != nil {
+			panic(&panics.Info{
+				Text:        "couldn't create the clickhouse tables",
+				Err:         err,
+				Attachments: []mailer.Attachable{logs.Log},
+			})
+		}
+	},
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/clcert/osr/databases"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/tasks"
+	_ "github.com/clcert/osr/tasks/registered"
+	"github.com/spf13/cobra"
+)
+
+var processType string
+
+func init() {
+	ProcessListCmd.Flags().StringVarP(&processType, "type", "t", "", "Show only processes of this type (import, transform or export)")
+	ProcessCmd.AddCommand(ProcessListCmd)
+	ProcessCmd.AddCommand(ProcessDescribeCmd)
+}
+
+// Process command groups the commands related to the registered processes.
+var ProcessCmd = &cobra.Command{
+	Use:   "process",
+	Short: "Shows the registered processes",
+	Long:  "Shows the importers, transforms and exporters that can be used in task files",
+}
+
+// ProcessList command lists the registered processes.
+var ProcessListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists registered processes",
+	Long:  "Lists registered importers, transforms and exporters, sorted by command",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		commands := make([]string, 0, len(tasks.Registered))
+		for command, process := range tasks.Registered {
+			if processType == "" || process.GetType() == processType {
+				commands = append(commands, command)
+			}
+		}
+		sort.Strings(commands)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "COMMAND\tTYPE\tNAME\tSOURCES\tSAVERS")
+		for _, command := range commands {
+			process := tasks.Registered[command]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				process.Command,
+				process.GetType(),
+				process.Name,
+				countString(process.NumSources),
+				countString(process.NumSavers))
+		}
+		return w.Flush()
+	},
+}
+
+// ProcessDescribe command shows a registered process, its data source and a skeleton task file to use it.
+var ProcessDescribeCmd = &cobra.Command{
+	Use:   "describe <command>",
+	Short: "Describes a process",
+	Long:  "Describes a registered process, with its params and data source, and prints a skeleton task file that uses it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		process, ok := tasks.Registered[args[0]]
+		if !ok {
+			return fmt.Errorf("process not defined in system: %s", args[0])
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		describeProcess(w, process)
+		fmt.Fprintln(w, "\nDATA SOURCE")
+		fmt.Fprintf(w, "ID:\t%d\n", process.DefaultSourceID)
+		if source, err := getDataSource(process.DefaultSourceID); err != nil {
+			fmt.Fprintf(w, "Error:\tcouldn't get source from database: %s\n", err)
+		} else {
+			fmt.Fprintf(w, "Name:\t%s\n", source.Name)
+			fmt.Fprintf(w, "URL:\t%s\n", source.URL)
+			fmt.Fprintf(w, "Description:\t%s\n", source.Description)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Println("\nSKELETON TASK FILE")
+		fmt.Print(process.Skeleton())
+		return nil
+	},
+}
+
+// describeProcess prints the metadata of a process and the params it accepts.
+func describeProcess(w *tabwriter.Writer, process *tasks.Process) {
+	fmt.Fprintf(w, "Name:\t%s\n", process.Name)
+	fmt.Fprintf(w, "Command:\t%s\n", process.Command)
+	fmt.Fprintf(w, "Type:\t%s\n", process.GetType())
+	fmt.Fprintf(w, "Description:\t%s\n", process.Description)
+	if process.URL != "" {
+		fmt.Fprintf(w, "URL:\t%s\n", process.URL)
+	}
+	fmt.Fprintf(w, "Sources:\t%s\n", countString(process.NumSources))
+	fmt.Fprintf(w, "Savers:\t%s\n", countString(process.NumSavers))
+	if len(process.Params) == 0 {
+		fmt.Fprintln(w, "\nThis process doesn't declare params.")
+		return
+	}
+	fmt.Fprintln(w, "\nPARAM\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+	for _, spec := range process.Params {
+		defaultValue := spec.Default
+		if defaultValue == "" {
+			defaultValue = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n",
+			spec.Name,
+			spec.TypeName(),
+			spec.Required,
+			defaultValue,
+			spec.Description)
+	}
+}
+
+// getDataSource returns the data source row with the given ID.
+func getDataSource(id models.DataSourceID) (*models.Source, error) {
+	db, err := databases.GetPostgresReader()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return models.GetSource(db, id)
+}
+
+// countString returns a number of sources or savers as string. Negative numbers mean unlimited.
+func countString(count int) string {
+	if count < 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(count)
+}
@@ -12,6 +12,7 @@ var inFiles []string
 var outFolder string
 var queries []string
 var headers bool
+var queryFormat string
 
 func init() {
 	RawQueryCmd.Flags().StringSliceVarP(&inFiles, "input-files", "i", []string{}, "Input files absolute path.")
@@ -19,6 +20,7 @@ func init() {
 	RawQueryCmd.Flags().StringSliceVarP(&queries, "queries", "q", []string{}, "whitelisted query names. Default is all.")
 	
 	RawQueryCmd.Flags().BoolVarP(&headers, "headers", "H", false, "Output CSV with column headers.")
+	RawQueryCmd.Flags().StringVarP(&queryFormat, "format", "f", "csv", "Output format: csv, jsonl, parquet or binary.")
 
 	RawQueryCmd.Flags().StringSliceVarP(&params, "params", "p", []string{}, "Parameters")
 
@@ -31,7 +33,7 @@ var RawQueryCmd = &cobra.Command{
 	Short: "executes PGSql queries from YAML files",
 	Long:  "executes PGSql queries from YAML files",
 	Run: func(cmd *cobra.Command, args []string) {
-		err := query.Execute(inFiles, outFolder, queries, headers, params)
+		err := query.Execute(inFiles, outFolder, queries, headers, queryFormat, params)
 		if err != nil {
 			panic(&panics.Info{
 				Text:        "fatal error executing the raw query",
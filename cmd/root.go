@@ -16,6 +16,10 @@ func init() {
 	RootCmd.AddCommand(InitCmd)
 	RootCmd.AddCommand(MailerCmd)
 	RootCmd.AddCommand(TaskCmd)
+	RootCmd.AddCommand(ProcessCmd)
+	RootCmd.AddCommand(SourceCmd)
+	RootCmd.AddCommand(RunCmd)
+	RootCmd.AddCommand(SchedulerCmd)
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(plot.PlotCmd)
 }
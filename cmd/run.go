@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/mailer"
+	"github.com/clcert/osr/panics"
+	"github.com/clcert/osr/savers"
+	"github.com/clcert/osr/sources"
+	"github.com/clcert/osr/tasks"
+	_ "github.com/clcert/osr/tasks/registered"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var runSources []string
+var runSavers []string
+var runParams []string
+var runIncognito bool
+
+func init() {
+	RunCmd.Flags().StringArrayVar(&runSources, "source", []string{}, "Source config, as inline YAML or as the path of a YAML file. It can be repeated.")
+	RunCmd.Flags().StringArrayVar(&runSavers, "saver", []string{}, "Saver config, as inline YAML or as the path of a YAML file. It can be repeated.")
+	RunCmd.Flags().StringSliceVarP(&runParams, "params", "p", []string{}, "Parameters")
+	RunCmd.Flags().BoolVar(&runIncognito, "incognito", false, "Doesn't register the task session in the database")
+}
+
+// Run command executes one process without a task file.
+var RunCmd = &cobra.Command{
+	Use:   "run <command>",
+	Short: "Executes a single process",
+	Long: "Executes a single process without a task file, using the sources and savers defined as flags. " +
+		"For example: osr run export/stdout --source 'sftp: {servername: server1, path: /data}'",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		processConfig := &tasks.ProcessConfig{
+			Command: args[0],
+			Sources: make([]sources.Config, len(runSources)),
+			Savers:  make([]savers.Config, len(runSavers)),
+		}
+		for i, value := range runSources {
+			if err := parseInlineConfig(value, &processConfig.Sources[i]); err != nil {
+				return fmt.Errorf("invalid source %d: %s", i, err)
+			}
+		}
+		for i, value := range runSavers {
+			if err := parseInlineConfig(value, &processConfig.Savers[i]); err != nil {
+				return fmt.Errorf("invalid saver %d: %s", i, err)
+			}
+		}
+		config := &tasks.TaskConfig{
+			Name:        fmt.Sprintf("run %s", args[0]),
+			Description: "Process executed with osr run",
+			Incognito:   runIncognito,
+			Processes:   []*tasks.ProcessConfig{processConfig},
+		}
+		if errs := config.Validate(runParams); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Printf("  - %s\n", err)
+			}
+			return fmt.Errorf("invalid process config")
+		}
+		logs.Log.WithFields(logrus.Fields{
+			"command":   processConfig.Command,
+			"sources":   len(processConfig.Sources),
+			"savers":    len(processConfig.Savers),
+			"incognito": runIncognito,
+		}).Info("executing single process")
+		task, err := tasks.New(config, runParams)
+		if err != nil {
+			panic(&panics.Info{
+				Text:        fmt.Sprintf("error executing process %s", processConfig.Command),
+				Err:         err,
+				Attachments: []mailer.Attachable{logs.Log},
+			})
+		}
+		defer task.Close()
+		ctx, cancel := signalContext()
+		defer cancel()
+		task.ExecuteContext(ctx)
+		if task.HasErrors() {
+			return fmt.Errorf("process failed: %s", task.GetFailed())
+		}
+		return nil
+	},
+}
+
+// parseInlineConfig parses a YAML config into a struct. The value is read from a file if it's the path
+// of an existing file. If not, it's parsed as inline YAML.
+func parseInlineConfig(value string, config interface{}) error {
+	var content string
+	if _, err := os.Stat(value); err == nil {
+		bytes, err := ioutil.ReadFile(value)
+		if err != nil {
+			return err
+		}
+		content = string(bytes)
+	} else {
+		content = value
+	}
+	viperConfig := viper.New()
+	viperConfig.SetConfigType("yaml")
+	if err := viperConfig.ReadConfig(strings.NewReader(content)); err != nil {
+		return err
+	}
+	return viperConfig.Unmarshal(config)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/clcert/osr/databases"
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/mailer"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/panics"
+	"github.com/clcert/osr/scheduler"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var nextCount int
+
+func init() {
+	SchedulerNextCmd.Flags().IntVarP(&nextCount, "count", "n", 10, "Number of executions to show.")
+	SchedulerCmd.AddCommand(SchedulerRunCmd)
+	SchedulerCmd.AddCommand(SchedulerListCmd)
+	SchedulerCmd.AddCommand(SchedulerNextCmd)
+}
+
+// Scheduler command groups the commands related to the task scheduler.
+var SchedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Executes task files periodically",
+	Long:  "Executes task files periodically, using the cron expressions defined in the scheduler section of the config file",
+}
+
+// SchedulerRun command starts the scheduler daemon.
+var SchedulerRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Starts the scheduler",
+	Long:  "Starts the scheduler. It stops when it receives SIGINT or SIGTERM, after waiting for the running tasks",
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := scheduler.New()
+		if err != nil {
+			panic(&panics.Info{
+				Text:        "couldn't parse scheduler config",
+				Err:         err,
+				Attachments: []mailer.Attachable{logs.Log},
+			})
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			logs.Log.WithFields(logrus.Fields{
+				"signal": sig,
+			}).Info("Signal received")
+			cancel()
+		}()
+		if err := s.Run(ctx); err != nil {
+			panic(&panics.Info{
+				Text:        "scheduler error",
+				Err:         err,
+				Attachments: []mailer.Attachable{logs.Log},
+			})
+		}
+	},
+}
+
+// SchedulerList command shows the entries of the scheduler and their last executions.
+var SchedulerListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists scheduler entries",
+	Long:  "Lists scheduler entries, with their next execution and the last execution of each task file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := scheduler.New()
+		if err != nil {
+			return err
+		}
+		db, err := databases.GetPostgresReader()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ENTRY\tCRON\tNEXT RUN\tTASK\tLAST RUN\tLAST TASK ID\tLAST STATUS")
+		for _, entry := range s.Entries {
+			scheduledTasks, err := models.GetScheduledTasks(db, entry.Name)
+			if err != nil {
+				logs.Log.WithFields(logrus.Fields{
+					"entry": entry.Name,
+				}).Errorf("Couldn't get last executions: %s", err)
+			}
+			lastRuns := make(map[string]*models.ScheduledTask)
+			for _, scheduled := range scheduledTasks {
+				lastRuns[scheduled.TaskFile] = scheduled
+			}
+			for _, taskFile := range entry.Tasks {
+				lastRun, lastTaskID, lastStatus := "-", "-", "-"
+				if scheduled, ok := lastRuns[taskFile]; ok {
+					lastRun = scheduled.LastRun.Format(time.RFC3339)
+					lastTaskID = fmt.Sprintf("%d", scheduled.LastTaskID)
+					lastStatus = scheduled.LastStatus.String()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					entry.Name,
+					entry.Cron,
+					entry.Next(now).Format(time.RFC3339),
+					taskFile,
+					lastRun,
+					lastTaskID,
+					lastStatus)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+// SchedulerNext command shows the next executions of the scheduler.
+var SchedulerNextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Shows the next executions",
+	Long:  "Shows the next executions of the scheduler entries, sorted by time",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := scheduler.New()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tENTRY\tTASKS")
+		for _, execution := range s.Upcoming(time.Now(), nextCount) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n",
+				execution.Time.Format(time.RFC3339),
+				execution.Entry.Name,
+				strings.Join(execution.Entry.Tasks, ", "))
+		}
+		return w.Flush()
+	},
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/clcert/osr/databases"
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var forgetAll bool
+
+func init() {
+	SourceForgetCmd.Flags().BoolVar(&forgetAll, "all", false, "Forgets the state of all the incremental sources")
+	SourceCmd.AddCommand(SourceListCmd)
+	SourceCmd.AddCommand(SourceForgetCmd)
+}
+
+// SourceCmd groups the commands related to the state of incremental sources.
+var SourceCmd = &cobra.Command{
+	Use:   "source",
+	Short: "Manages the state of incremental sources",
+	Long:  "Lists and resets the entries consumed by the sources with incremental: true",
+}
+
+// SourceListCmd lists the incremental sources with consumed entries.
+var SourceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the incremental sources with consumed entries",
+	Long:  "Lists the IDs of the incremental sources, with the number of entries they consumed and the date of the last one",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db, err := databases.GetPostgresReader()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		consumed, err := models.GetConsumedSources(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE ID\tENTRIES\tLAST CONSUMED")
+		for _, source := range consumed {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", source.SourceID, source.Entries, source.LastConsumed.Format(time.RFC3339))
+		}
+		return w.Flush()
+	},
+}
+
+// SourceForgetCmd deletes the state of incremental sources.
+var SourceForgetCmd = &cobra.Command{
+	Use:   "forget [sourceID...]",
+	Short: "Forgets the entries consumed by incremental sources",
+	Long:  "Deletes the state of the entries consumed by incremental sources, so all their entries are consumed again on the next execution. Source IDs are listed by osr source list",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !forgetAll {
+			return fmt.Errorf("no source ID in args (use --all to forget all the sources)")
+		}
+		if len(args) > 0 && forgetAll {
+			return fmt.Errorf("source IDs cannot be used with --all")
+		}
+		db, err := databases.GetPostgresWriter()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		deleted, err := models.ForgetConsumedEntries(db, args...)
+		if err != nil {
+			return err
+		}
+		logs.Log.WithFields(logrus.Fields{
+			"sources": args,
+			"all":     forgetAll,
+			"entries": deleted,
+		}).Info("Incremental sources state forgotten")
+		fmt.Printf("%d consumed entries forgotten\n", deleted)
+		return nil
+	},
+}
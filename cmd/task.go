@@ -1,20 +1,47 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"github.com/clcert/osr/databases"
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/mailer"
+	"github.com/clcert/osr/models"
 	"github.com/clcert/osr/panics"
 	"github.com/clcert/osr/tasks"
 	_ "github.com/clcert/osr/tasks/registered"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 var params []string
+var resumeID int
+var validate bool
+var historyStatus string
+var historySince string
+var purgeDryRun bool
+var purgeForce bool
 
 func init() {
 	TaskCmd.Flags().StringSliceVarP(&params, "params", "p", []string{}, "Parameters")
+	TaskCmd.Flags().IntVarP(&resumeID, "resume", "r", 0, "ID of an interrupted task session to resume. Processes and entries that already succeeded are skipped.")
+	TaskCmd.Flags().BoolVar(&validate, "validate", false, "Only validates the task files, without executing them nor connecting to the database.")
+	TaskHistoryCmd.Flags().StringVarP(&historyStatus, "status", "s", "", "Show only task sessions with this status (processing, success, fail or purged)")
+	TaskHistoryCmd.Flags().StringVar(&historySince, "since", "", "Show only task sessions started after this date (YYYY-MM-DD) or in this period (e.g. 48h, 90d)")
+	TaskPurgeCmd.Flags().BoolVar(&purgeDryRun, "dry-run", false, "Only prints the number of rows to delete on each model")
+	TaskPurgeCmd.Flags().BoolVar(&purgeForce, "force", false, "Purges the task session even if it is still being processed. Use it only if its execution crashed.")
+	TaskCmd.AddCommand(TaskHistoryCmd)
+	TaskCmd.AddCommand(TaskShowCmd)
+	TaskCmd.AddCommand(TaskPurgeCmd)
+	TaskCmd.AddCommand(TaskDescribeCmd)
 }
 
 // Process executes a batch of process defined in a conf file.
@@ -26,7 +53,23 @@ var TaskCmd = &cobra.Command{
 		if len(args) == 0 {
 			return fmt.Errorf("no task file in args")
 		}
+		if validate {
+			return validateTasks(args)
+		}
+		if resumeID > 0 && len(args) > 1 {
+			return fmt.Errorf("only one task file can be resumed at a time")
+		}
+		// On SIGINT or SIGTERM, running processes are cancelled, savers are flushed and
+		// the task session is marked as failed. The remaining task files are not executed.
+		ctx, cancel := signalContext()
+		defer cancel()
 		for _, configName := range args {
+			if ctx.Err() != nil {
+				logs.Log.WithFields(logrus.Fields{
+					"file": configName,
+				}).Warn("Task cancelled, skipping task file")
+				continue
+			}
 			config, err := tasks.ParseConfig(configName)
 			if err != nil {
 				logs.Log.WithFields(logrus.Fields{
@@ -44,7 +87,12 @@ var TaskCmd = &cobra.Command{
 				"global_params": config.Params,
 				"file":          configName,
 			}).Info("executing task file")
-			task, err := tasks.New(config, params)
+			var task *tasks.Task
+			if resumeID > 0 {
+				task, err = tasks.Resume(config, params, resumeID)
+			} else {
+				task, err = tasks.New(config, params)
+			}
 			if err != nil {
 				panic(&panics.Info{
 					Text:        fmt.Sprintf("error executing task with name %s", configName),
@@ -52,8 +100,291 @@ var TaskCmd = &cobra.Command{
 					Attachments: []mailer.Attachable{logs.Log},
 				})
 			}
-			task.Execute()
+			task.ExecuteContext(ctx)
+		}
+		return nil
+	},
+}
+
+// signalContext returns a context cancelled when the command receives SIGINT or SIGTERM.
+// The returned function cancels the context and stops listening to the signals.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			logs.Log.WithFields(logrus.Fields{
+				"signal": sig,
+			}).Warn("Signal received, cancelling task...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// validateTasks validates task files and prints the problems found on them.
+// It returns an error if any of them is invalid.
+func validateTasks(files []string) error {
+	invalid := 0
+	for _, configName := range files {
+		config, err := tasks.ParseConfig(configName)
+		if err != nil {
+			fmt.Printf("%s: file parsing error: %s\n", configName, err)
+			invalid++
+			continue
+		}
+		errs := config.Validate(params)
+		if len(errs) == 0 {
+			fmt.Printf("%s: OK\n", configName)
+			continue
+		}
+		invalid++
+		fmt.Printf("%s: %d problem(s) found\n", configName, len(errs))
+		for _, err := range errs {
+			fmt.Printf("  - %s\n", err)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d task file(s) are invalid", invalid, len(files))
+	}
+	return nil
+}
+
+// TaskHistory command lists the past task sessions.
+var TaskHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Lists past task sessions",
+	Long:  "Lists past task sessions, with their status and duration",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var status *models.TaskStatus
+		if historyStatus != "" {
+			parsed, err := models.ParseTaskStatus(historyStatus)
+			if err != nil {
+				return err
+			}
+			status = &parsed
+		}
+		since, err := parseSince(historySince)
+		if err != nil {
+			return err
+		}
+		db, err := databases.GetPostgresReader()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		sessions, err := models.GetTasks(db, status, since)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATUS\tSTART\tEND\tDURATION")
+		for _, session := range sessions {
+			end, duration := "-", "-"
+			if !session.EndDate.IsZero() {
+				end = session.EndDate.Format(time.RFC3339)
+				duration = session.Duration().Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+				session.ID,
+				session.Status,
+				session.StartDate.Format(time.RFC3339),
+				end,
+				duration)
+		}
+		return w.Flush()
+	},
+}
+
+// TaskShow command shows the details of a task session.
+var TaskShowCmd = &cobra.Command{
+	Use:   "show <taskID>",
+	Short: "Shows a task session",
+	Long:  "Shows a task session, with its processes, the number of rows it created on each model and its log files",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		taskID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid task ID: %s", args[0])
+		}
+		db, err := databases.GetPostgresReader()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		session, err := models.GetTask(db, taskID)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%d\n", session.ID)
+		fmt.Fprintf(w, "Status:\t%s\n", session.Status)
+		fmt.Fprintf(w, "Start:\t%s\n", session.StartDate.Format(time.RFC3339))
+		if !session.EndDate.IsZero() {
+			fmt.Fprintf(w, "End:\t%s\n", session.EndDate.Format(time.RFC3339))
+			fmt.Fprintf(w, "Duration:\t%s\n", session.Duration().Round(time.Second))
+		}
+
+		processes, err := models.GetTaskProcesses(db, taskID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "\nPROCESS\tCOMMAND\tSTATUS\tDURATION\tENTRIES READ\tROWS INSERTED\tROWS ERRORED\tERROR")
+		for _, process := range processes {
+			duration := "-"
+			if !process.StartDate.IsZero() && !process.EndDate.IsZero() {
+				duration = process.EndDate.Sub(process.StartDate).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+				process.ProcessIndex,
+				process.Command,
+				process.Status,
+				duration,
+				process.EntriesRead,
+				process.RowsInserted,
+				process.RowsErrored,
+				process.Error)
+		}
+
+		fmt.Fprintln(w, "\nPROCESS\tSAVER\tOUTID\tINSERTED\tERRORED")
+		for _, process := range processes {
+			for i, saverStats := range process.SaverStats {
+				outIDs := make([]string, 0, len(saverStats))
+				for outID := range saverStats {
+					outIDs = append(outIDs, outID)
+				}
+				sort.Strings(outIDs)
+				for _, outID := range outIDs {
+					fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%d\n",
+						process.ProcessIndex,
+						i,
+						outID,
+						saverStats[outID].Inserted,
+						saverStats[outID].Errors)
+				}
+			}
+		}
+
+		fmt.Fprintln(w, "\nMODEL\tROWS")
+		for _, model := range models.DefaultModels.Models {
+			if !model.HasTaskID() {
+				continue
+			}
+			count, err := model.CountByTaskID(db, taskID)
+			if err != nil {
+				logs.Log.WithFields(logrus.Fields{
+					"model": model.Name,
+				}).Errorf("Couldn't count rows: %s", err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\n", model.Name, count)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		logPaths, err := tasks.GetLogPaths(taskID)
+		if err != nil {
+			return err
+		}
+		fmt.Println("\nLogs:")
+		for _, logPath := range logPaths {
+			fmt.Println(logPath)
 		}
 		return nil
 	},
 }
+
+// TaskPurge command deletes the data imported by a task session.
+var TaskPurgeCmd = &cobra.Command{
+	Use:   "purge <taskID>",
+	Short: "Deletes the data imported by a task session",
+	Long:  "Deletes the rows created by a task session on every model with a task ID, in one transaction, and marks the session as purged",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		taskID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid task ID: %s", args[0])
+		}
+		db, err := databases.GetPostgresWriter()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		session, err := models.GetTask(db, taskID)
+		if err != nil {
+			return err
+		}
+		results, err := session.Purge(db, &models.DefaultModels, purgeDryRun, purgeForce)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if purgeDryRun {
+			fmt.Fprintln(w, "MODEL\tROWS TO DELETE")
+		} else {
+			fmt.Fprintln(w, "MODEL\tROWS DELETED")
+		}
+		total := 0
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%d\n", result.Model.Name, result.Rows)
+			total += result.Rows
+		}
+		fmt.Fprintf(w, "Total\t%d\n", total)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !purgeDryRun {
+			logs.Log.WithFields(logrus.Fields{
+				"task_id": taskID,
+				"rows":    total,
+			}).Info("Task session purged")
+		}
+		return nil
+	},
+}
+
+// TaskDescribe command shows a registered process and the params it accepts.
+var TaskDescribeCmd = &cobra.Command{
+	Use:   "describe <command>",
+	Short: "Describes a process and its params",
+	Long:  "Describes a registered process and the schema of the params it accepts",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		process, ok := tasks.Registered[args[0]]
+		if !ok {
+			return fmt.Errorf("process not defined in system: %s", args[0])
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		describeProcess(w, process)
+		return w.Flush()
+	},
+}
+
+// parseSince parses a date (YYYY-MM-DD) or a period before now (a duration, or a number of days like 90d).
+// An empty string returns the zero time.
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if strings.HasSuffix(since, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(since, "d")); err == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if duration, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", since, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date or period: %s", since)
+	}
+	return date, nil
+}
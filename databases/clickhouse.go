@@ -2,6 +2,9 @@ package databases
 
 import (
 	"fmt"
+	"net/url"
+
+	_ "github.com/ClickHouse/clickhouse-go"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -15,27 +18,29 @@ func GetClickhouseReader() (*sqlx.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	fConnStr := fmt.Sprintf(clickhouseConnStr,
-		conf.Server,
-		conf.Port,
-		conf.DBName,
-		conf.Reader.Username,
-		conf.Reader.Password)
-	return sqlx.Open("clickhouse", fConnStr)
+	return openClickhouse(conf, conf.Reader)
 }
 
-// GeetClickhouseWriter returns a sqlx struct with a "connection" to a clickhouse database
+// GetClickhouseWriter returns a sqlx struct with a "connection" to a clickhouse database
 // with read and write permissions
 func GetClickhouseWriter() (*sqlx.DB, error) {
 	conf, err := GetDBConfig("clickhouse")
 	if err != nil {
 		return nil, err
 	}
+	return openClickhouse(conf, conf.Writer)
+}
+
+// openClickhouse opens a clickhouse database using the given credentials.
+func openClickhouse(conf Config, creds Credentials) (*sqlx.DB, error) {
+	if conf.Server == "" {
+		return nil, fmt.Errorf("clickhouse database is not configured")
+	}
 	fConnStr := fmt.Sprintf(clickhouseConnStr,
 		conf.Server,
 		conf.Port,
-		conf.DBName,
-		conf.Reader.Username,
-		conf.Reader.Password)
+		url.QueryEscape(conf.DBName),
+		url.QueryEscape(creds.Username),
+		url.QueryEscape(creds.Password))
 	return sqlx.Open("clickhouse", fConnStr)
 }
@@ -4,6 +4,7 @@ go 1.11
 
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/ClickHouse/clickhouse-go v1.5.4
 	github.com/PuerkitoBio/goquery v1.5.1
 	github.com/fatih/structs v1.1.0
 	github.com/go-pg/pg v8.0.7+incompatible
@@ -13,16 +14,20 @@ require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/jordan-wright/email v0.0.0-20190218024454-3ea4d25e7cf8
+	github.com/klauspost/compress v1.15.15
 	github.com/kr/fs v0.1.0 // indirect
 	github.com/lib/pq v1.0.0
-	github.com/pkg/errors v0.8.1 // indirect
+	github.com/minio/minio-go/v7 v7.0.10
+	github.com/pierrec/lz4/v4 v4.1.17
 	github.com/pkg/sftp v1.10.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sirupsen/logrus v1.4.2
-	github.com/spf13/afero v1.2.0 // indirect
 	github.com/spf13/cobra v0.0.3
 	github.com/spf13/viper v1.3.1
-	golang.org/x/crypto v0.0.0-20201117144127-c1f2f97bffc9
-	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
+	github.com/ulikunitz/xz v0.5.11
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20220315005136-aec0fe3e777c // indirect
+	golang.org/x/crypto v0.0.0-20210513164829-c07d793c2f9a
 	golang.org/x/text v0.3.3
 	gonum.org/v1/netlib v0.0.0-20190331212654-76723241ea4e // indirect
 	gonum.org/v1/plot v0.0.0-20190615073203-9aa86143727f
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -44,6 +45,28 @@ func (log *OSRLog) GetAttachments() []string {
 	return []string{log.Path}
 }
 
+// FindLogs returns the paths of the log files whose name contains the given string.
+func FindLogs(name string) ([]string, error) {
+	logsPath, err := getLogsPath()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0)
+	err = filepath.Walk(logsPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.Contains(info.Name(), name) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return paths, nil
+	}
+	return paths, err
+}
+
 // createLogName creates a log name, with the date and time of the log in the name.
 func createLogName(name string) string {
 	dateLayout := "2006-01-02"
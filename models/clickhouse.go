@@ -0,0 +1,336 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clcert/osr/databases"
+	"github.com/clcert/osr/logs"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/sirupsen/logrus"
+)
+
+// ClickhouseConfig defines how the rows of a model are stored in ClickHouse.
+type ClickhouseConfig struct {
+	OrderBy     []string // Sorting key of the MergeTree table. By default, the primary key columns of the model
+	PartitionBy string   // Partition key of the MergeTree table (e.g. "toYYYYMM(date)"). If empty, the table is not partitioned
+}
+
+// ClickhouseTable describes the ClickHouse table where the objects of a struct are stored.
+// Like the Postgres table of the struct, its name and columns are defined by the struct fields and their pg tags.
+type ClickhouseTable struct {
+	Name    string              // Escaped name of the table
+	Type    reflect.Type        // Type of the struct
+	Columns []*ClickhouseColumn // Columns of the table
+	pks     []string            // Escaped names of the primary key columns
+}
+
+// ClickhouseColumn describes a column of a ClickHouse table.
+type ClickhouseColumn struct {
+	Name     string     // Escaped name of the column
+	Type     string     // ClickHouse type of the column, without Nullable
+	Nullable bool       // If true, zero values are stored as NULL, like in Postgres when the field is not tagged with use_zero
+	Default  string     // ClickHouse expression of the Postgres default of the field, or empty if it has none or it's not supported
+	field    *orm.Field // Struct field of the column
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	ipType   = reflect.TypeOf(net.IP{})
+)
+
+// GetClickhouseTable returns the ClickHouse table of a struct type. It returns an error if a field
+// of the struct has a type which cannot be stored in ClickHouse.
+func GetClickhouseTable(structType reflect.Type) (*ClickhouseTable, error) {
+	for structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot store %s in clickhouse: it's not a struct", structType)
+	}
+	table := orm.GetTable(structType)
+	chTable := &ClickhouseTable{
+		Name:    string(table.SQLName),
+		Type:    structType,
+		Columns: make([]*ClickhouseColumn, len(table.Fields)),
+		pks:     make([]string, len(table.PKs)),
+	}
+	for i, pk := range table.PKs {
+		chTable.pks[i] = string(pk.Column)
+	}
+	for i, field := range table.Fields {
+		chType, err := clickhouseType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("cannot store field %s of %s in clickhouse: %s", field.GoName, structType, err)
+		}
+		chTable.Columns[i] = &ClickhouseColumn{
+			Name:     string(field.Column),
+			Type:     chType,
+			Nullable: field.NullZero() && !chTable.isPK(string(field.Column)),
+			Default:  clickhouseDefault(field, chType),
+			field:    field,
+		}
+	}
+	return chTable, nil
+}
+
+// clickhouseDefault returns the ClickHouse expression of the Postgres default of a field.
+// Only now() on dates and numeric literals on numbers are supported, other defaults are ignored.
+func clickhouseDefault(field *orm.Field, chType string) string {
+	pgDefault := string(field.Default)
+	switch {
+	case pgDefault == "":
+		return ""
+	case chType == "DateTime64(6)":
+		if strings.ToLower(pgDefault) == "now()" {
+			return "now64(6)"
+		}
+	case strings.HasPrefix(chType, "Int"), strings.HasPrefix(chType, "UInt"), strings.HasPrefix(chType, "Float"):
+		if _, err := strconv.ParseFloat(pgDefault, 64); err == nil {
+			return pgDefault
+		}
+	}
+	return ""
+}
+
+// clickhouseType returns the ClickHouse type of a Go type.
+func clickhouseType(goType reflect.Type) (string, error) {
+	switch goType {
+	case timeType:
+		return "DateTime64(6)", nil
+	case ipType:
+		return "IPv6", nil
+	}
+	switch goType.Kind() {
+	case reflect.Ptr:
+		return clickhouseType(goType.Elem())
+	case reflect.Bool, reflect.Uint8:
+		return "UInt8", nil
+	case reflect.Uint16:
+		return "UInt16", nil
+	case reflect.Uint32:
+		return "UInt32", nil
+	case reflect.Uint, reflect.Uint64:
+		return "UInt64", nil
+	case reflect.Int8:
+		return "Int8", nil
+	case reflect.Int16:
+		return "Int16", nil
+	case reflect.Int32:
+		return "Int32", nil
+	case reflect.Int, reflect.Int64:
+		return "Int64", nil
+	case reflect.Float32:
+		return "Float32", nil
+	case reflect.Float64:
+		return "Float64", nil
+	case reflect.String:
+		return "String", nil
+	default:
+		return "", fmt.Errorf("unsupported type %s", goType)
+	}
+}
+
+// isPK returns true if the column is part of the primary key of the model.
+func (table *ClickhouseTable) isPK(column string) bool {
+	for _, pk := range table.pks {
+		if pk == column {
+			return true
+		}
+	}
+	return false
+}
+
+// InsertStatement returns the statement which inserts a row on the table, with a placeholder for each column.
+func (table *ClickhouseTable) InsertStatement() string {
+	columns := make([]string, len(table.Columns))
+	placeholders := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columns[i] = column.Name
+		placeholders[i] = "?"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table.Name, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+}
+
+// Values returns the values of the columns of an object, converted to the types used by the ClickHouse driver.
+func (table *ClickhouseTable) Values(object interface{}) ([]interface{}, error) {
+	value := reflect.Indirect(reflect.ValueOf(object))
+	if value.Type() != table.Type {
+		return nil, fmt.Errorf("object of type %s cannot be saved on the table of %s", value.Type(), table.Type)
+	}
+	values := make([]interface{}, len(table.Columns))
+	for i, column := range table.Columns {
+		values[i] = column.value(value)
+	}
+	return values, nil
+}
+
+// value returns the value of the column in a struct. Like Postgres, zero values of columns with a default
+// are replaced by it. The rows are inserted with all their columns, so ClickHouse doesn't apply the default.
+func (column *ClickhouseColumn) value(strct reflect.Value) interface{} {
+	if column.field.HasZeroValue(strct) {
+		if column.Default != "" {
+			return column.defaultValue()
+		}
+		if column.Nullable {
+			return nil
+		}
+	}
+	return convertClickhouseValue(column.field.Value(strct))
+}
+
+// defaultValue returns the value of the default of the column.
+func (column *ClickhouseColumn) defaultValue() interface{} {
+	if column.Type == "DateTime64(6)" {
+		return time.Now()
+	}
+	v := reflect.New(column.field.Type).Elem()
+	for v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, _ := strconv.ParseInt(column.Default, 10, 64)
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, _ := strconv.ParseUint(column.Default, 10, 64)
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, _ := strconv.ParseFloat(column.Default, 64)
+		v.SetFloat(n)
+	}
+	return convertClickhouseValue(v)
+}
+
+// convertClickhouseValue converts a value to the type used by the ClickHouse driver.
+func convertClickhouseValue(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+	switch v.Type() {
+	case timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			// DateTime64 cannot store Go zero time
+			return time.Unix(0, 0)
+		}
+		return t
+	case ipType:
+		ip := v.Interface().(net.IP)
+		if ip == nil {
+			return net.IPv6zero
+		}
+		return ip
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return uint8(1)
+		}
+		return uint8(0)
+	case reflect.Uint8:
+		return uint8(v.Uint())
+	case reflect.Uint16:
+		return uint16(v.Uint())
+	case reflect.Uint32:
+		return uint32(v.Uint())
+	case reflect.Uint, reflect.Uint64:
+		return v.Uint()
+	case reflect.Int8:
+		return int8(v.Int())
+	case reflect.Int16:
+		return int16(v.Int())
+	case reflect.Int32:
+		return int32(v.Int())
+	case reflect.Int, reflect.Int64:
+		return v.Int()
+	case reflect.Float32:
+		return float32(v.Float())
+	case reflect.Float64:
+		return v.Float()
+	default:
+		return v.String()
+	}
+}
+
+// ClickhouseDDL returns the statement which creates the ClickHouse table of the model, using the MergeTree engine.
+func (m *Model) ClickhouseDDL() (string, error) {
+	table, err := GetClickhouseTable(reflect.TypeOf(m.StructType))
+	if err != nil {
+		return "", err
+	}
+	config := m.Clickhouse
+	if config == nil {
+		config = &ClickhouseConfig{}
+	}
+	orderBy := config.OrderBy
+	if len(orderBy) == 0 {
+		orderBy = table.pks
+	}
+	if len(orderBy) == 0 {
+		return "", fmt.Errorf("model %s has no primary key, so it needs a clickhouse sorting key", m.Name)
+	}
+	columns := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		chType := column.Type
+		if column.Nullable {
+			chType = "Nullable(" + chType + ")"
+		}
+		columns[i] = fmt.Sprintf("\t%s %s", column.Name, chType)
+		if column.Default != "" {
+			columns[i] += " DEFAULT " + column.Default
+		}
+	}
+	var ddl strings.Builder
+	fmt.Fprintf(&ddl, "CREATE TABLE IF NOT EXISTS %s (\n%s\n) ENGINE = MergeTree()", table.Name, strings.Join(columns, ",\n"))
+	if config.PartitionBy != "" {
+		fmt.Fprintf(&ddl, "\nPARTITION BY %s", config.PartitionBy)
+	}
+	fmt.Fprintf(&ddl, "\nORDER BY (%s)", strings.Join(orderBy, ", "))
+	return ddl.String(), nil
+}
+
+// ClickhouseModels returns the models of the list which are stored in ClickHouse.
+func (s *ModelsList) ClickhouseModels() []Model {
+	chModels := make([]Model, 0)
+	for _, m := range s.Models {
+		if m.Clickhouse != nil {
+			chModels = append(chModels, m)
+		}
+	}
+	return chModels
+}
+
+// CreateClickhouseTables creates the ClickHouse tables of the models of the list which are stored in ClickHouse.
+func (s *ModelsList) CreateClickhouseTables() error {
+	db, err := databases.GetClickhouseWriter()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, m := range s.ClickhouseModels() {
+		ddl, err := m.ClickhouseDDL()
+		if err != nil {
+			return err
+		}
+		logs.Log.WithFields(logrus.Fields{
+			"model": m.Name,
+		}).Info("Creating clickhouse table for model...")
+		if _, err := db.Exec(ddl); err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"model": m.Name,
+			}).Errorf("Error creating clickhouse table for model: %s", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ConsumedEntryModel contains the metainformation related to the respective model.
+var ConsumedEntryModel = Model{
+	Name:        "Consumed Entry",
+	Description: "Entries consumed by incremental sources, skipped on the next executions if they didn't change",
+	StructType:  &ConsumedEntry{},
+}
+
+// ConsumedEntry represents the state of an entry of an incremental source when it was consumed.
+type ConsumedEntry struct {
+	SourceID   string    `pg:",pk"`       // ID of the source
+	Path       string    `pg:",pk"`       // Path of the entry
+	Size       int64     `pg:",use_zero"` // Size of the entry, if the source knows it
+	ModTime    time.Time // Modification time of the entry, if the source knows it
+	Hash       string    // SHA-256 of the content of the entry, if it was read completely
+	ConsumedAt time.Time // Date of the last execution which consumed the entry
+	TaskID     int       `pg:",type:bigint"` // Task session of the last execution which consumed the entry. Purging it resets the state of the entry
+}
+
+// ConsumedSource summarizes the entries consumed by an incremental source.
+type ConsumedSource struct {
+	SourceID     string    // ID of the source
+	Entries      int       // Number of consumed entries
+	LastConsumed time.Time // Date of the last consumed entry
+}
+
+// SaveConsumedEntries inserts or updates the state of a list of consumed entries.
+func SaveConsumedEntries(db *pg.DB, entries []*ConsumedEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err := db.Model(&entries).
+		OnConflict("(source_id, path) DO UPDATE").
+		Set("size = EXCLUDED.size").
+		Set("mod_time = EXCLUDED.mod_time").
+		Set("hash = EXCLUDED.hash").
+		Set("consumed_at = EXCLUDED.consumed_at").
+		Set("task_id = EXCLUDED.task_id").
+		Insert()
+	return err
+}
+
+// GetConsumedEntries returns the entries consumed by a source, indexed by their paths.
+func GetConsumedEntries(db *pg.DB, sourceID string) (map[string]*ConsumedEntry, error) {
+	entries := make([]*ConsumedEntry, 0)
+	err := db.Model(&entries).
+		Where("source_id = ?", sourceID).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*ConsumedEntry, len(entries))
+	for _, entry := range entries {
+		byPath[entry.Path] = entry
+	}
+	return byPath, nil
+}
+
+// GetConsumedSources returns a summary of the entries consumed by each source.
+func GetConsumedSources(db *pg.DB) ([]*ConsumedSource, error) {
+	consumed := make([]*ConsumedSource, 0)
+	err := db.Model((*ConsumedEntry)(nil)).
+		Column("source_id").
+		ColumnExpr("count(*) AS entries").
+		ColumnExpr("max(consumed_at) AS last_consumed").
+		Group("source_id").
+		Order("source_id").
+		Select(&consumed)
+	return consumed, err
+}
+
+// ForgetConsumedEntries deletes the state of the entries consumed by a list of sources,
+// so their entries are consumed again on the next execution. If no source ID is given,
+// the state of all the sources is deleted. It returns the number of deleted entries.
+func ForgetConsumedEntries(db *pg.DB, sourceIDs ...string) (int, error) {
+	query := db.Model((*ConsumedEntry)(nil))
+	if len(sourceIDs) > 0 {
+		query = query.WhereIn("source_id IN (?)", sourceIDs)
+	} else {
+		query = query.Where("TRUE")
+	}
+	result, err := query.Delete()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -15,6 +15,10 @@ var DarknetPacketModel = Model{
 		"CREATE INDEX IF NOT EXISTS darknet_packet_index ON ?TableName USING gist (src_ip inet_ops)",
 		"CREATE INDEX IF NOT EXISTS darknet_timestamp ON ?TableName USING btree (time)",
 	},
+	Clickhouse: &ClickhouseConfig{
+		OrderBy:     []string{"time", "src_ip", "dst_ip", "dst_port"},
+		PartitionBy: "toYYYYMM(time)",
+	},
 }
 
 // DarknetPacket represents the TCP/IP headers of a darknet packet.
@@ -14,7 +14,12 @@ var DnsRRModel = Model{
 		"CREATE INDEX IF NOT EXISTS dns_rr_index ON ?TableName USING gist (ip_value inet_ops)",
 		"CREATE INDEX IF NOT EXISTS dns_rr_timestamp ON ?TableName USING btree (date)",
 		"SELECT partman.create_parent('public.dns_rrs', 'date', 'native', 'weekly');",
-	}}
+	},
+	Clickhouse: &ClickhouseConfig{
+		OrderBy:     []string{"date", "domain_name", "domain_subdomain", "scan_type"},
+		PartitionBy: "toYYYYMM(date)",
+	},
+}
 
 // RRType maps a number to an RR type.
 type RRType int
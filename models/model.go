@@ -30,6 +30,7 @@ type Model struct {
 	AfterCreateStmts     []string    // Statements to execute after the creation of the model
 	BeforeCreateFunction func(db *pg.DB) error
 	AfterCreateFunction  func(db *pg.DB) error
+	Clickhouse           *ClickhouseConfig // If defined, the model can be stored in ClickHouse, and its table is created by "models clickhouse createdb"
 }
 
 // A ModelsList is a group of  Currently we use only
@@ -169,6 +170,22 @@ func (m *Model) AfterCreateTable(db *pg.DB) error {
 	return nil
 }
 
+// HasTaskID returns true if the model has a TaskID field, relating its rows to the task session
+// which created them.
+func (m *Model) HasTaskID() bool {
+	_, ok := structs.New(m.StructType).FieldOk("TaskID")
+	return ok
+}
+
+// CountByTaskID returns the number of rows of the model created by a task session.
+// It returns an error if the model doesn't have a TaskID field.
+func (m *Model) CountByTaskID(db orm.DB, taskID int) (int, error) {
+	if !m.HasTaskID() {
+		return 0, fmt.Errorf("model %s has no TaskID field", m.Name)
+	}
+	return db.Model(m.StructType).Where("task_id = ?", taskID).Count()
+}
+
 // Append appends a model to a model list.
 func (s *ModelsList) Append(m Model) {
 	s.Models = append(s.Models, m)
@@ -21,6 +21,10 @@ var PortScanModel = Model{
 		"CREATE INDEX IF NOT EXISTS port_scan_source_id ON ?TableName USING btree (source_id)",
 		"SELECT partman.create_parent('public.port_scans', 'date', 'native', 'weekly');",
 	},
+	Clickhouse: &ClickhouseConfig{
+		OrderBy:     []string{"date", "ip", "port_number", "protocol"},
+		PartitionBy: "toYYYYMM(date)",
+	},
 }
 
 // PortProtocol represents the transport protocol checked in a port scan.
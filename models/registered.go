@@ -7,6 +7,10 @@ var DefaultModels = ModelsList{
 	Models: []Model{
 		// Core Models
 		TaskModel,
+		TaskProcessModel,
+		TaskEntryModel,
+		ConsumedEntryModel,
+		ScheduledTaskModel,
 		// Basic Metainfo
 		SourceModel,
 		ContactModel,
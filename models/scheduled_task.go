@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// ScheduledTaskModel contains the metainformation related to the respective model.
+var ScheduledTaskModel = Model{
+	Name:        "Scheduled Task",
+	Description: "Last executions of the task files triggered by the scheduler",
+	StructType:  &ScheduledTask{},
+}
+
+// ScheduledTask represents the last execution of a task file triggered by a scheduler entry.
+type ScheduledTask struct {
+	Entry      string     `pg:",pk,type:varchar(255)"` // Name of the scheduler entry
+	TaskFile   string     `pg:",pk,type:varchar(512)"` // Task file executed, relative to the tasks folder
+	LastRun    time.Time  // Last time the task file was triggered
+	LastTaskID int        // ID of the task session of the last execution
+	LastStatus TaskStatus `pg:",use_zero"` // Status of the last execution
+}
+
+// Save inserts or updates the scheduled task.
+func (scheduled *ScheduledTask) Save(db *pg.DB) error {
+	_, err := db.Model(scheduled).
+		OnConflict("(entry, task_file) DO UPDATE").
+		Set("last_run = EXCLUDED.last_run").
+		Set("last_task_id = EXCLUDED.last_task_id").
+		Set("last_status = EXCLUDED.last_status").
+		Insert()
+	return err
+}
+
+// GetScheduledTasks returns the last executions of all the task files of a scheduler entry.
+func GetScheduledTasks(db *pg.DB, entry string) ([]*ScheduledTask, error) {
+	scheduled := make([]*ScheduledTask, 0)
+	err := db.Model(&scheduled).
+		Where("entry = ?", entry).
+		Select()
+	return scheduled, err
+}
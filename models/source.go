@@ -31,6 +31,16 @@ type Source struct {
 	Description string       // Source Description
 }
 
+// GetSource returns the source with the given ID.
+func GetSource(db *pg.DB, id DataSourceID) (*Source, error) {
+	source := &Source{ID: id}
+	err := db.Model(source).WherePK().Select()
+	if err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
 // TODO: Extract this information from another source
 func createSources(db *pg.DB) error {
 	sources := []Source{
@@ -1,9 +1,13 @@
 package models
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 )
 
 var TaskModel = Model{
@@ -20,12 +24,14 @@ const (
 	PROCESSING TaskStatus = iota // The task is being processed
 	SUCCESS                      // The task finished and it was successful
 	FAIL                         // The task finished, but it failed.
+	PURGED                       // The data imported by the task was deleted.
 )
 
 var statusToString = map[TaskStatus]string{
 	PROCESSING: "Processing",
 	SUCCESS:    "Success",
 	FAIL:       "Failure",
+	PURGED:     "Purged",
 }
 
 // The struct represents an task session
@@ -36,6 +42,30 @@ type Task struct {
 	Status    TaskStatus `pg:",use_zero"` // Task session status
 }
 
+// String returns a human readable name for the status.
+func (status TaskStatus) String() string {
+	return statusToString[status]
+}
+
+// ParseTaskStatus returns the status with the given name. It accepts the human readable
+// names of the statuses and the names of their constants, ignoring case.
+func ParseTaskStatus(name string) (TaskStatus, error) {
+	for status, statusName := range statusToString {
+		if strings.EqualFold(name, statusName) {
+			return status, nil
+		}
+	}
+	switch strings.ToLower(name) {
+	case "processing":
+		return PROCESSING, nil
+	case "success":
+		return SUCCESS, nil
+	case "fail":
+		return FAIL, nil
+	}
+	return PROCESSING, fmt.Errorf("unknown task status: %s", name)
+}
+
 func (task *Task) GetStatus() string {
 	return statusToString[task.Status]
 }
@@ -55,6 +85,49 @@ func NewTaskSession(db *pg.DB, save bool) (*Task, error) {
 	return newImport, nil
 }
 
+// GetTask returns the task session with the given ID.
+func GetTask(db *pg.DB, id int) (*Task, error) {
+	task := &Task{ID: id}
+	err := db.Model(task).WherePK().Select()
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetTasks returns the task sessions started after a date, sorted from the newest to the oldest one.
+// If status is not nil, only the sessions with that status are returned.
+func GetTasks(db *pg.DB, status *TaskStatus, since time.Time) ([]*Task, error) {
+	tasks := make([]*Task, 0)
+	query := db.Model(&tasks).
+		Where("start_date >= ?", since).
+		Order("id DESC")
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	err := query.Select()
+	return tasks, err
+}
+
+// Duration returns the duration of the task session, or zero if it has not finished yet.
+func (task *Task) Duration() time.Duration {
+	if task.EndDate.IsZero() {
+		return 0
+	}
+	return task.EndDate.Sub(task.StartDate)
+}
+
+// Resume marks a failed or interrupted task session as processing again. It returns an error if the
+// session succeeded or was purged. Remember to save this status.
+func (task *Task) Resume() error {
+	if task.Status != FAIL && task.Status != PROCESSING {
+		return fmt.Errorf("task %d cannot be resumed because its status is %s", task.ID, task.GetStatus())
+	}
+	task.EndDate = time.Time{}
+	task.Status = PROCESSING
+	return nil
+}
+
 // Returns the latest global task ID
 func LatestTaskID(db *pg.DB) (id int, err error) {
 	err = db.Model(&Task{}).
@@ -86,7 +159,7 @@ func (task *Task) Succeeded() {
 }
 
 // Save Saves the status of the task session.
-func (task *Task) Save(db *pg.DB) (err error) {
+func (task *Task) Save(db orm.DB) (err error) {
 	if task.ID == 0 {
 		_, err = db.Model(task).Insert()
 	} else {
@@ -94,3 +167,76 @@ func (task *Task) Save(db *pg.DB) (err error) {
 	}
 	return
 }
+
+// PurgeResult is the number of rows of a model deleted when purging a task session.
+type PurgeResult struct {
+	Model *Model // Model purged
+	Rows  int    // Number of rows created by the task session
+}
+
+// Purge deletes the rows created by the task session on every model of the list with a TaskID field,
+// in one transaction, and marks the session as purged. The execution records of the session processes
+// are kept. The state of the entries consumed by incremental sources on the session is also deleted,
+// so they are consumed again on the next execution. If dryRun is true, it only counts the rows, without deleting them.
+// Sessions still being processed are only purged if force is true.
+func (task *Task) Purge(db *pg.DB, list *ModelsList, dryRun, force bool) ([]*PurgeResult, error) {
+	if err := task.checkPurgeable(force); err != nil {
+		return nil, err
+	}
+	results := make([]*PurgeResult, 0)
+	err := db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		for i := range list.Models {
+			model := &list.Models[i]
+			if !model.HasTaskID() || model.keepOnPurge() {
+				continue
+			}
+			var rows int
+			if dryRun {
+				count, err := model.CountByTaskID(tx, task.ID)
+				if err != nil {
+					return fmt.Errorf("cannot count rows of %s: %s", model.Name, err)
+				}
+				rows = count
+			} else {
+				result, err := tx.Model(model.StructType).Where("task_id = ?", task.ID).Delete()
+				if err != nil {
+					return fmt.Errorf("cannot delete rows of %s: %s", model.Name, err)
+				}
+				rows = result.RowsAffected()
+			}
+			results = append(results, &PurgeResult{
+				Model: model,
+				Rows:  rows,
+			})
+		}
+		if dryRun {
+			return nil
+		}
+		task.Status = PURGED
+		return task.Save(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkPurgeable returns an error if the task session cannot be purged. Sessions still being processed
+// are accepted only if force is true, because a session whose execution crashed never leaves that status.
+func (task *Task) checkPurgeable(force bool) error {
+	if task.Status == PROCESSING && !force {
+		return fmt.Errorf("task %d is still being processed (use force if its execution crashed)", task.ID)
+	}
+	return nil
+}
+
+// keepOnPurge returns true if the rows of the model must not be deleted when a task session is purged.
+// They are the records of the execution of the session, not data imported by it.
+func (m *Model) keepOnPurge() bool {
+	switch m.StructType.(type) {
+	case *TaskProcess, *TaskEntry:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// TaskProcessModel contains the metainformation related to the respective model.
+var TaskProcessModel = Model{
+	Name:        "Task Process",
+	Description: "Execution records of the processes of a task session",
+	StructType:  &TaskProcess{},
+}
+
+// TaskEntryModel contains the metainformation related to the respective model.
+var TaskEntryModel = Model{
+	Name:        "Task Entry",
+	Description: "Source entries consumed by the processes of a task session",
+	StructType:  &TaskEntry{},
+}
+
+type ProcessStatus int
+
+// This constants represent the current status of
+// a process in a task session.
+const (
+	PENDING   ProcessStatus = iota // The process has not been executed yet
+	RUNNING                        // The process is being executed
+	SUCCEEDED                      // The process finished and it was successful
+	FAILED                         // The process finished, but it failed
+	SKIPPED                        // The process was not executed because a dependency didn't succeed
+)
+
+var processStatusToString = map[ProcessStatus]string{
+	PENDING:   "Pending",
+	RUNNING:   "Running",
+	SUCCEEDED: "Succeeded",
+	FAILED:    "Failed",
+	SKIPPED:   "Skipped",
+}
+
+// TaskProcess represents the execution of a process of a task session.
+type TaskProcess struct {
+	TaskID       int               `pg:",pk"`          // ID of the task session
+	Task         *Task             `pg:"rel:has-one"`  // Task structure
+	ProcessIndex int               `pg:",pk,use_zero"` // Index of the process in the task file
+	Command      string            `pg:",use_zero"`    // Command of the process
+	Params       map[string]string // Params used by the process, including task and command line params
+	StartDate    time.Time         // Process start date
+	EndDate      time.Time         // Process end date
+	Status       ProcessStatus     `pg:",use_zero"` // Status of the process
+	Error        string            // Error returned by the process, if it failed
+	EntriesRead  int               `pg:",use_zero"` // Number of entries read from the sources of the process
+	RowsInserted int               `pg:",use_zero"` // Number of rows saved by the savers of the process
+	RowsErrored  int               `pg:",use_zero"` // Number of rows the savers of the process couldn't save
+	SaverStats   []SaverStats      // Rows saved and errored by each saver of the process, by outID
+}
+
+// SaverStats contains the number of rows saved and errored by a saver, by outID.
+type SaverStats map[string]*OutIDStats
+
+// OutIDStats contains the number of rows with the same outID saved and errored by a saver.
+type OutIDStats struct {
+	Inserted int // Number of rows saved
+	Errors   int // Number of rows which couldn't be saved
+}
+
+// TaskEntry represents a source entry consumed by a process of a task session.
+type TaskEntry struct {
+	TaskID       int    `pg:",pk"`          // ID of the task session
+	Task         *Task  `pg:"rel:has-one"`  // Task structure
+	ProcessIndex int    `pg:",pk,use_zero"` // Index of the process in the task file
+	SourceIndex  int    `pg:",pk,use_zero"` // Index of the source in the process
+	Path         string `pg:",pk"`          // Path of the entry
+}
+
+// String returns a human readable name for the status.
+func (status ProcessStatus) String() string {
+	return processStatusToString[status]
+}
+
+// Save inserts or updates the execution record of the process.
+func (process *TaskProcess) Save(db *pg.DB) error {
+	_, err := db.Model(process).
+		OnConflict("(task_id, process_index) DO UPDATE").
+		Set("command = EXCLUDED.command").
+		Set("params = EXCLUDED.params").
+		Set("start_date = EXCLUDED.start_date").
+		Set("end_date = EXCLUDED.end_date").
+		Set("status = EXCLUDED.status").
+		Set("error = EXCLUDED.error").
+		Set("entries_read = EXCLUDED.entries_read").
+		Set("rows_inserted = EXCLUDED.rows_inserted").
+		Set("rows_errored = EXCLUDED.rows_errored").
+		Set("saver_stats = EXCLUDED.saver_stats").
+		Insert()
+	return err
+}
+
+// GetTaskProcesses returns the execution records of the processes of a task session.
+func GetTaskProcesses(db *pg.DB, taskID int) ([]*TaskProcess, error) {
+	processes := make([]*TaskProcess, 0)
+	err := db.Model(&processes).
+		Where("task_id = ?", taskID).
+		Order("process_index").
+		Select()
+	return processes, err
+}
+
+// SaveTaskEntries saves a list of consumed entries. Entries already saved are ignored.
+func SaveTaskEntries(db *pg.DB, entries []*TaskEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	_, err := db.Model(&entries).
+		OnConflict("DO NOTHING").
+		Insert()
+	return err
+}
+
+// GetTaskEntries returns the entries consumed by the processes of a task session.
+func GetTaskEntries(db *pg.DB, taskID int) ([]*TaskEntry, error) {
+	entries := make([]*TaskEntry, 0)
+	err := db.Model(&entries).
+		Where("task_id = ?", taskID).
+		Select()
+	return entries, err
+}
+
+// GetCommandProcesses returns the execution records of a command started after a date,
+// sorted from the newest to the oldest one.
+func GetCommandProcesses(db *pg.DB, command string, since time.Time) ([]*TaskProcess, error) {
+	processes := make([]*TaskProcess, 0)
+	err := db.Model(&processes).
+		Where("command = ?", command).
+		Where("start_date >= ?", since).
+		Order("start_date DESC").
+		Select()
+	return processes, err
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskResume(t *testing.T) {
+	tests := []struct {
+		status    TaskStatus
+		resumable bool
+	}{
+		{PROCESSING, true},
+		{FAIL, true},
+		{SUCCESS, false},
+		{PURGED, false},
+	}
+	for _, test := range tests {
+		t.Run(test.status.String(), func(t *testing.T) {
+			task := &Task{ID: 1, Status: test.status, EndDate: time.Now()}
+			err := task.Resume()
+			if !test.resumable {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				if task.Status != test.status {
+					t.Errorf("expected status to remain %s, got %s", test.status, task.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if task.Status != PROCESSING || !task.EndDate.IsZero() {
+				t.Errorf("expected a processing session without end date, got %s ending on %s", task.Status, task.EndDate)
+			}
+		})
+	}
+}
+
+func TestTaskCheckPurgeable(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    TaskStatus
+		force     bool
+		purgeable bool
+	}{
+		{"processing", PROCESSING, false, false},
+		{"processing with force", PROCESSING, true, true},
+		{"failed", FAIL, false, true},
+		{"succeeded", SUCCESS, false, true},
+		{"purged", PURGED, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := (&Task{ID: 1, Status: test.status}).checkPurgeable(test.force)
+			if test.purgeable && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if !test.purgeable && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
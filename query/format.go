@@ -0,0 +1,235 @@
+package query
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-pg/pg/v10/types"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Output formats of the exported queries.
+const (
+	CSV     = "csv"     // CSV, with optional headers
+	JSONL   = "jsonl"   // A JSON object per row
+	Parquet = "parquet" // Parquet file, with a column per query column
+	Binary  = "binary"  // Postgres binary COPY format
+)
+
+// ParquetString is the Parquet type of the columns exported as strings.
+const ParquetString = "type=BYTE_ARRAY, convertedtype=UTF8"
+
+// Postgres OIDs of the types with their own Parquet type. The other types are exported as strings.
+const (
+	boolOID        = 16
+	int8OID        = 20
+	int2OID        = 21
+	int4OID        = 23
+	float4OID      = 700
+	float8OID      = 701
+	dateOID        = 1082
+	timestampOID   = 1114
+	timestamptzOID = 1184
+)
+
+// Extension returns the file extension of an output format, or an error if the format is unknown.
+// An empty format is CSV.
+func Extension(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", CSV:
+		return ".csv", nil
+	case JSONL:
+		return ".jsonl", nil
+	case Parquet:
+		return ".parquet", nil
+	case Binary:
+		return ".bin", nil
+	default:
+		return "", fmt.Errorf("unknown query format: %s", format)
+	}
+}
+
+// exportStatement returns the COPY statement which exports the query on a format. Parquet files are not
+// exported using COPY, so it returns an empty statement for them.
+func (entry *Query) exportStatement(format string, headers bool) (string, error) {
+	switch strings.ToLower(format) {
+	case "", CSV:
+		stmt := "COPY (" + entry.SQL + ") TO STDOUT WITH CSV"
+		if headers {
+			stmt += " HEADER"
+		}
+		return stmt, nil
+	case JSONL:
+		// The quote and delimiter characters are escaped by JSON, so the rows are not quoted by COPY
+		return "COPY (SELECT row_to_json(osr_row) FROM (" + entry.SQL + ") osr_row) TO STDOUT WITH CSV QUOTE E'\\x01' DELIMITER E'\\x02'", nil
+	case Binary:
+		return "COPY (" + entry.SQL + ") TO STDOUT WITH BINARY", nil
+	case Parquet:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown query format: %s", format)
+	}
+}
+
+// parquetModel scans the rows of a query, writing them on a Parquet file.
+// The schema of the file is defined by the columns of the first row.
+type parquetModel struct {
+	file       io.Writer          // Output file
+	writer     *writer.CSVWriter  // Parquet writer, created when the first row is scanned
+	columns    []types.ColumnInfo // Columns of the query
+	row        []interface{}      // Values of the row being scanned
+	rows       int                // Number of rows written
+	schemaOnly bool               // If true, the scanned row is only used to create the writer
+}
+
+// exportParquet executes a query, writing its rows on a Parquet file.
+// If the query has no rows, its columns are obtained from a row of nulls, so the file is still valid.
+func (entry *Query) exportParquet(db *pg.DB, file io.Writer) (int, error) {
+	model := &parquetModel{
+		file: file,
+	}
+	if _, err := db.Query(model, entry.SQL); err != nil {
+		return model.rows, err
+	}
+	if model.writer == nil {
+		model.columns = nil
+		model.schemaOnly = true
+		if _, err := db.Query(model, "SELECT osr_row.* FROM (SELECT 1) osr_one LEFT JOIN ("+entry.SQL+") osr_row ON false"); err != nil {
+			return 0, err
+		}
+		if model.writer == nil {
+			return 0, fmt.Errorf("cannot get the columns of the query")
+		}
+	}
+	return model.rows, model.writer.WriteStop()
+}
+
+// Init is called before the rows are scanned.
+func (model *parquetModel) Init() error {
+	return nil
+}
+
+// NextColumnScanner returns the scanner of the next row.
+func (model *parquetModel) NextColumnScanner() orm.ColumnScanner {
+	model.row = make([]interface{}, 0, len(model.columns))
+	return model
+}
+
+// AddColumnScanner writes the scanned row.
+func (model *parquetModel) AddColumnScanner(_ orm.ColumnScanner) error {
+	if model.writer == nil {
+		if err := model.newWriter(); err != nil {
+			return err
+		}
+	}
+	if model.schemaOnly {
+		return nil
+	}
+	if err := model.writer.Write(model.row); err != nil {
+		return err
+	}
+	model.rows++
+	return nil
+}
+
+// ScanColumn converts a column value from its Postgres text representation to its Parquet type.
+func (model *parquetModel) ScanColumn(col types.ColumnInfo, rd types.Reader, n int) error {
+	if model.writer == nil && int(col.Index) == len(model.columns) {
+		model.columns = append(model.columns, col)
+	}
+	if n == -1 {
+		model.row = append(model.row, nil)
+		return nil
+	}
+	b, err := rd.ReadFullTemp()
+	if err != nil {
+		return err
+	}
+	value, err := parquetValue(col.DataType, string(b))
+	if err != nil {
+		return fmt.Errorf("cannot convert column %s: %s", col.Name, err)
+	}
+	model.row = append(model.row, value)
+	return nil
+}
+
+// newWriter creates the Parquet writer, using the columns of the first row as schema.
+func (model *parquetModel) newWriter() error {
+	metadata := make([]string, len(model.columns))
+	for i, col := range model.columns {
+		field, err := ParquetField(col.Name, parquetType(col.DataType))
+		if err != nil {
+			return err
+		}
+		metadata[i] = field
+	}
+	parquetWriter, err := writer.NewCSVWriterFromWriter(metadata, model.file, 1)
+	if err != nil {
+		return err
+	}
+	parquetWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+	model.writer = parquetWriter
+	return nil
+}
+
+// ParquetField returns the schema of an optional Parquet column, as used by the CSV writer of parquet-go.
+// The schema is a list of key=value pairs separated by commas, so names containing them are rejected.
+func ParquetField(name, parquetType string) (string, error) {
+	if strings.ContainsAny(name, ",=") {
+		return "", fmt.Errorf("parquet column name cannot contain commas or equal signs: %q", name)
+	}
+	return fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", name, parquetType), nil
+}
+
+// parquetType returns the Parquet type and converted type of a Postgres type.
+func parquetType(oid int32) string {
+	switch oid {
+	case boolOID:
+		return "type=BOOLEAN"
+	case int2OID, int4OID:
+		return "type=INT32"
+	case int8OID:
+		return "type=INT64"
+	case float4OID:
+		return "type=FLOAT"
+	case float8OID:
+		return "type=DOUBLE"
+	case dateOID:
+		return "type=INT32, convertedtype=DATE"
+	case timestampOID, timestamptzOID:
+		return "type=INT64, convertedtype=TIMESTAMP_MICROS"
+	default:
+		return ParquetString
+	}
+}
+
+// parquetValue converts a value from its Postgres text representation to the Go type used by its Parquet type.
+func parquetValue(oid int32, value string) (interface{}, error) {
+	switch oid {
+	case boolOID:
+		return value == "t", nil
+	case int2OID, int4OID:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return int32(v), err
+	case int8OID:
+		return strconv.ParseInt(value, 10, 64)
+	case float4OID:
+		v, err := strconv.ParseFloat(value, 32)
+		return float32(v), err
+	case float8OID:
+		return strconv.ParseFloat(value, 64)
+	case dateOID:
+		t, err := types.ParseTimeString(value)
+		return int32(t.Unix() / (24 * 60 * 60)), err
+	case timestampOID, timestamptzOID:
+		t, err := types.ParseTimeString(value)
+		return t.UnixNano() / 1000, err
+	default:
+		return value, nil
+	}
+}
@@ -31,6 +31,7 @@ type Config struct {
 	Path      string
 	Whitelist []string
 	Params    utils.Params
+	Format    string // Output format of the queries: csv (default), jsonl, parquet or binary
 }
 
 // Defines a query file configuration.
@@ -38,6 +39,9 @@ type Config struct {
 type Configs []*Config
 
 func (config *Config) Open() (map[string]*Query, error) {
+	if _, err := Extension(config.Format); err != nil {
+		return nil, err
+	}
 	queries, err := OpenFile(config.Path, config.Params)
 	if err != nil {
 		return nil, err
@@ -63,20 +67,24 @@ func (entry *Query) Execute(db *pg.DB, params ...interface{}) (pg.Result, error)
 	return db.Exec(entry.SQL, params...)
 }
 
+// Export exports the results of the query to a file, using CSV format.
 func (entry *Query) Export(db *pg.DB, file io.Writer, headers bool) chan error {
+	return entry.ExportFormat(db, file, CSV, headers)
+}
+
+// ExportFormat exports the results of the query to a file, using one of the formats defined in this package.
+// headers is only used by CSV format.
+func (entry *Query) ExportFormat(db *pg.DB, file io.Writer, format string, headers bool) chan error {
 	chErr := make(chan error)
 	logs.Log.WithFields(logrus.Fields{
 		"query":       entry.Name,
 		"description": entry.Description,
 		"SQL":         entry.SQL,
+		"format":      format,
 	}).Info("Executing query...")
 
-	stmt := "COPY (" + entry.SQL + ") TO STDOUT WITH CSV"
-	if headers {
-		stmt += " HEADER"
-	}
 	go func() {
-		result, err := db.CopyTo(file, stmt)
+		rows, err := entry.export(db, file, format, headers)
 		if err != nil {
 			logs.Log.WithFields(logrus.Fields{
 				"query": entry.Name,
@@ -84,7 +92,7 @@ func (entry *Query) Export(db *pg.DB, file io.Writer, headers bool) chan error {
 		} else {
 			logs.Log.WithFields(logrus.Fields{
 				"query":         entry.Name,
-				"rows_affected": result.RowsAffected(),
+				"rows_affected": rows,
 			}).Info("Query done!")
 		}
 		chErr <- err
@@ -93,6 +101,22 @@ func (entry *Query) Export(db *pg.DB, file io.Writer, headers bool) chan error {
 	return chErr
 }
 
+// export exports the results of the query, returning the number of rows exported.
+func (entry *Query) export(db *pg.DB, file io.Writer, format string, headers bool) (int, error) {
+	stmt, err := entry.exportStatement(format, headers)
+	if err != nil {
+		return 0, err
+	}
+	if stmt == "" {
+		return entry.exportParquet(db, file)
+	}
+	result, err := db.CopyTo(file, stmt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 // Creates a new Configs instance with the edited fields.
 func (queries Configs) Format(params utils.Params) Configs {
 	newList := make(Configs, len(queries))
@@ -102,6 +126,7 @@ func (queries Configs) Format(params utils.Params) Configs {
 			Path:      params.FormatString(queryConfig.Path),
 			Params:    newParams,
 			Whitelist: params.FormatStringArray(queryConfig.Whitelist),
+			Format:    params.FormatString(queryConfig.Format),
 		}
 	}
 	return newList
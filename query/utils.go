@@ -17,7 +17,12 @@ import (
 )
 
 // Export executes queries defined in inFolder. If whitelist is not empty, it uses only the queries of it.
-func Execute(queryFiles []string, outFolder string, whitelist []string, headers bool, params []string) error {
+// The results are saved using the format defined by this package (CSV by default).
+func Execute(queryFiles []string, outFolder string, whitelist []string, headers bool, format string, params []string) error {
+	extension, err := Extension(format)
+	if err != nil {
+		return err
+	}
 	db, err := databases.GetPostgresReader()
 	if err != nil {
 		return err
@@ -54,7 +59,7 @@ func Execute(queryFiles []string, outFolder string, whitelist []string, headers
 			}
 		}
 		for _, query := range whitelisted {
-			newFilePath := filepath.Join(outFolderPath, query.Name+".csv")
+			newFilePath := filepath.Join(outFolderPath, query.Name+extension)
 			logs.Log.WithFields(logrus.Fields{
 				"new_file_path": newFilePath,
 			}).Info("Opening destination file...")
@@ -62,8 +67,10 @@ func Execute(queryFiles []string, outFolder string, whitelist []string, headers
 			if err != nil {
 				return err
 			}
-			chErr := query.Export(db, newFile, headers)
-			if <-chErr != nil {
+			chErr := query.ExportFormat(db, newFile, format, headers)
+			err = <-chErr
+			newFile.Close()
+			if err != nil {
 				return err
 			}
 		}
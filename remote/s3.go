@@ -0,0 +1,53 @@
+package remote
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/spf13/viper"
+)
+
+// S3Server defines an S3 compatible object storage (like MinIO), as declared in the "s3"
+// section of the config file.
+type S3Server struct {
+	Name      string // name of the storage
+	Endpoint  string // Host and port of the storage (e.g. localhost:9000)
+	AccessKey string // Access key of the storage user
+	SecretKey string // Secret key of the storage user
+	Region    string // Region of the buckets. It can be empty
+	UseSSL    bool   // If true, the connection uses HTTPS
+}
+
+// GetS3Servers returns a list with the S3 storages defined in config file.
+func GetS3Servers() ([]*S3Server, error) {
+	var serverList []*S3Server
+	err := viper.UnmarshalKey("s3", &serverList)
+	return serverList, err
+}
+
+// GetS3Server returns an specific S3 storage configuration, based on the name provided.
+func GetS3Server(serverName string) (*S3Server, error) {
+	servers, err := GetS3Servers()
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range servers {
+		if server.Name == serverName {
+			return server, nil
+		}
+	}
+	return nil, fmt.Errorf("s3 server %s not found in config file", serverName)
+}
+
+// NewClient returns a client connected to the storage.
+func (s *S3Server) NewClient() (*minio.Client, error) {
+	if s.Endpoint == "" {
+		return nil, fmt.Errorf("s3 server %s has no endpoint defined", s.Name)
+	}
+	return minio.New(s.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(s.AccessKey, s.SecretKey, ""),
+		Secure: s.UseSSL,
+		Region: s.Region,
+	})
+}
@@ -0,0 +1,333 @@
+package savers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/clcert/osr/databases"
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultClickhouseBuffer is the default number of objects by outID inserted at once on ClickHouse.
+const DefaultClickhouseBuffer = 100000
+
+// ClickhouseConfig defines a configuration for a ClickHouse saver.
+type ClickhouseConfig struct {
+	Buffer        int               // Number of objects by outID to hold before inserting them. ClickHouse works better with big inserts, so the default is 100000
+	InsertTimeout time.Duration     // Number of seconds to wait to insert the held objects if there are no new objects received.
+	Tables        map[string]string // Table where the objects of each outID or struct are saved. By default, it's the table of the struct model
+}
+
+// ClickhouseSaver defines a saver which inserts structs in the configured ClickHouse database.
+// The objects of each outID are inserted in batches, using the native protocol of ClickHouse.
+// The tables must exist, and their columns are derived from the structs like the Postgres ones.
+type ClickhouseSaver struct {
+	*ClickhouseConfig                              // Configuration related to the saver
+	name              string                       // Name for the saver instance
+	chanMutex         sync.Mutex                   // Mutex to edit the branches map concurrently
+	mutex             sync.Mutex                   // Protects the inserted objects and errors
+	db                *sqlx.DB                     // ClickHouse connection
+	wg                sync.WaitGroup               // Wait Group to wait the finish of all branches
+	branches          map[string]*clickhouseBranch // A map of the branches receiving objects, identified by the outID of the objects
+	inserted          int                          // Number of inserted objects
+	stats             outIDCounter                 // Objects saved and errored by outID
+	errors            []error                      // List of errors
+	log               *logs.OSRLog                 // Saver log
+	ctx               context.Context              // Context of the process using the saver
+}
+
+// clickhouseBranch receives the objects of an outID. When it's closed, its goroutine inserts the objects held
+// and removes the branch from the saver, so the next objects of the outID start a new branch.
+type clickhouseBranch struct {
+	objects chan Savable  // Channel of the objects to insert
+	done    chan struct{} // Closed when the branch must insert the objects held and stop
+}
+
+// New creates a new ClickhouseSaver based on a ClickhouseConfig struct.
+func (config *ClickhouseConfig) New(name string, params utils.Params) (*ClickhouseSaver, error) {
+	config = config.Format(params)
+	if config.InsertTimeout == 0 {
+		config.InsertTimeout = 10 // default timeout
+	}
+	if config.Buffer == 0 {
+		config.Buffer = DefaultClickhouseBuffer
+	}
+	log, err := logs.NewLog(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ClickhouseSaver{
+		ClickhouseConfig: config,
+		name:             name,
+		branches:         make(map[string]*clickhouseBranch),
+		errors:           make([]error, 0),
+		log:              log,
+	}, nil
+}
+
+// Format returns a copy of the configuration, formatted with the params defined in the task.
+func (config *ClickhouseConfig) Format(params utils.Params) *ClickhouseConfig {
+	newConfig := &ClickhouseConfig{
+		Buffer:        config.Buffer,
+		InsertTimeout: config.InsertTimeout,
+		Tables:        make(map[string]string, len(config.Tables)),
+	}
+	for k, v := range config.Tables {
+		newConfig.Tables[params.FormatString(k)] = params.FormatString(v)
+	}
+	return newConfig
+}
+
+func (saver *ClickhouseSaver) Start(ctx context.Context) error {
+	saver.ctx = ctx
+	db, err := databases.GetClickhouseWriter()
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return err
+	}
+	saver.db = db
+	return nil
+}
+
+// SendMessage accepts the same close messages as the Postgres saver, inserting the objects held for an outID.
+func (saver *ClickhouseSaver) SendMessage(msg interface{}) error {
+	msgMap, ok := msg.(map[string]string)
+	if !ok {
+		return fmt.Errorf("message not understood")
+	}
+	closeID, ok := msgMap["close"]
+	if !ok {
+		return fmt.Errorf("message not understood")
+	}
+	saver.chanMutex.Lock()
+	defer saver.chanMutex.Unlock()
+	branch, ok := saver.branches[closeID]
+	if !ok || branch.isClosed() {
+		return fmt.Errorf("channel not found: %s", closeID)
+	}
+	close(branch.done)
+	return nil
+}
+
+func (saver *ClickhouseSaver) Save(objs ...interface{}) error {
+	if saver.ctx != nil && saver.ctx.Err() != nil {
+		return saver.ctx.Err()
+	}
+	for _, obj := range objs {
+		var savable Savable
+		switch obj.(type) {
+		case Savable:
+			savable = obj.(Savable)
+		default:
+			savable = Savable{Object: obj}
+		}
+		saver.send(savable)
+	}
+	return nil
+}
+
+func (saver *ClickhouseSaver) Finish() error {
+	saver.chanMutex.Lock()
+	for _, branch := range saver.branches {
+		if !branch.isClosed() {
+			close(branch.done)
+		}
+	}
+	saver.chanMutex.Unlock()
+	saver.wg.Wait()
+	return saver.db.Close()
+}
+
+func (saver *ClickhouseSaver) GetErrors() []error {
+	saver.mutex.Lock()
+	defer saver.mutex.Unlock()
+	return saver.errors
+}
+
+func (saver *ClickhouseSaver) GetInserted() int {
+	saver.mutex.Lock()
+	defer saver.mutex.Unlock()
+	return saver.inserted
+}
+
+func (saver *ClickhouseSaver) GetStats() models.SaverStats {
+	return saver.stats.copy()
+}
+
+func (saver *ClickhouseSaver) GetAttachments() []string {
+	return []string{saver.log.Path}
+}
+
+func (saver *ClickhouseSaver) GetName() string {
+	return saver.name
+}
+
+// send sends an object to the branch of its outID. If the branch is closed before receiving it,
+// the object is sent to a new branch.
+func (saver *ClickhouseSaver) send(savable Savable) {
+	for {
+		branch := saver.getBranch(savable.GetOutID())
+		select {
+		case branch.objects <- savable:
+			return
+		case <-branch.done:
+		}
+	}
+}
+
+// getBranch returns the branch of an outID, starting a new one if it doesn't exist or if it was closed.
+func (saver *ClickhouseSaver) getBranch(name string) *clickhouseBranch {
+	saver.chanMutex.Lock()
+	defer saver.chanMutex.Unlock()
+	branch, ok := saver.branches[name]
+	if ok && !branch.isClosed() {
+		return branch
+	}
+	branch = &clickhouseBranch{
+		objects: make(chan Savable),
+		done:    make(chan struct{}),
+	}
+	saver.branches[name] = branch
+	saver.wg.Add(1)
+	go saver.startBranch(name, branch)
+	return branch
+}
+
+// isClosed returns true if the branch was closed.
+func (branch *clickhouseBranch) isClosed() bool {
+	select {
+	case <-branch.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// startBranch receives the objects of an outID, inserting them when the buffer is full, when
+// no objects are received in some time or when the branch is closed.
+func (saver *ClickhouseSaver) startBranch(name string, branch *clickhouseBranch) {
+	defer saver.wg.Done()
+	var table *models.ClickhouseTable
+	rows := make([][]interface{}, 0)
+L:
+	for {
+		select {
+		case newObject := <-branch.objects:
+			if table == nil {
+				var err error
+				table, err = saver.getTable(newObject)
+				if err != nil {
+					saver.addError(name, 1, err)
+					continue
+				}
+			}
+			values, err := table.Values(newObject.Object)
+			if err != nil {
+				saver.addError(name, 1, err)
+				continue
+			}
+			rows = append(rows, values)
+			if len(rows) >= saver.Buffer {
+				saver.insert(name, table, rows)
+				rows = make([][]interface{}, 0, saver.Buffer)
+			}
+		case <-time.After(saver.InsertTimeout * time.Second):
+			if len(rows) > 0 {
+				saver.log.WithFields(logrus.Fields{
+					"outID":   name,
+					"number":  len(rows),
+					"timeout": saver.InsertTimeout,
+				}).Info("No object received in some time, saving...")
+				saver.insert(name, table, rows)
+				rows = make([][]interface{}, 0, saver.Buffer)
+			}
+		case <-branch.done:
+			break L
+		}
+	}
+	if len(rows) > 0 {
+		saver.insert(name, table, rows)
+	}
+	saver.log.WithFields(logrus.Fields{
+		"outID": name,
+	}).Info("Done, deleting saver branch...")
+	// A new branch of the outID may have replaced this one after it was closed
+	saver.chanMutex.Lock()
+	if saver.branches[name] == branch {
+		delete(saver.branches, name)
+	}
+	saver.chanMutex.Unlock()
+}
+
+// getTable returns the table where the objects of the type of an object are saved.
+func (saver *ClickhouseSaver) getTable(object Savable) (*models.ClickhouseTable, error) {
+	table, err := models.GetClickhouseTable(reflect.TypeOf(object.Object))
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := saver.Tables[object.GetOutID()]; ok {
+		table.Name = name
+	} else if name, ok := saver.Tables[object.StructName()]; ok {
+		table.Name = name
+	}
+	return table, nil
+}
+
+// insert inserts a batch of rows on a table. The driver sends the rows as a single block when the transaction
+// is committed, so a batch is inserted completely or not at all.
+func (saver *ClickhouseSaver) insert(outID string, table *models.ClickhouseTable, rows [][]interface{}) {
+	saver.log.WithFields(logrus.Fields{
+		"outID":  outID,
+		"table":  table.Name,
+		"number": len(rows),
+	}).Info("Inserting entries into clickhouse...")
+	tx, err := saver.db.Begin()
+	if err != nil {
+		saver.addError(outID, len(rows), err)
+		return
+	}
+	stmt, err := tx.Prepare(table.InsertStatement())
+	if err != nil {
+		_ = tx.Rollback()
+		saver.addError(outID, len(rows), err)
+		return
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			_ = tx.Rollback()
+			saver.addError(outID, len(rows), err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		saver.addError(outID, len(rows), err)
+		return
+	}
+	saver.mutex.Lock()
+	saver.inserted += len(rows)
+	saver.mutex.Unlock()
+	saver.stats.addInserted(outID, len(rows))
+}
+
+// addError logs and stores an error which made n objects of an outID not to be saved.
+func (saver *ClickhouseSaver) addError(outID string, n int, err error) {
+	saver.log.WithFields(logrus.Fields{
+		"outID":  outID,
+		"number": n,
+	}).Errorf("Couldn't insert entries to clickhouse: %v", err)
+	saver.mutex.Lock()
+	saver.errors = append(saver.errors, err)
+	saver.mutex.Unlock()
+	saver.stats.addErrors(outID, n)
+}
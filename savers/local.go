@@ -0,0 +1,504 @@
+package savers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/query"
+	"github.com/clcert/osr/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// LocalConfig defines a configuration for a local saver.
+// FileConfig and Append work like in the SFTP saver.
+type LocalConfig struct {
+	Path       string                     // Folder where the files are saved. If the folder doesn't exist, it's created
+	FileType   string                     // Type of the files: csv (default), jsonl or parquet
+	Gzip       bool                       // If true, the files are compressed with gzip, adding ".gz" to their names. Parquet files are compressed internally with gzip instead
+	RotateRows int                        // If positive, a new file is started after writing this number of rows, adding "-1", "-2", etc. to the file name. Rotated files cannot be appended
+	ForceFlush bool                       // If it's true, the file lines are written immediately. Its slower but safer (if the routine crashes, it saves some data). It's ignored with parquet
+	FileConfig map[string]*SFTPFileConfig // A map with the outID of the savables as the key, and a configuration as a value.
+	Append     bool                       // If true, all non defined files are append by default. Parquet files cannot be appended
+}
+
+// LocalSaver defines a saver which saves the objects in files on a local folder, one by outID.
+type LocalSaver struct {
+	*LocalConfig                       // LocalConfig related to the saver
+	name         string                // Name for the saver instance
+	outFiles     map[string]*LocalFile // A map with file writers, where the key is the outID.
+	finished     chan bool             // True if channel finished parsing files
+	objects      chan Savable          // List of objects to save
+	inserted     int                   // number of inserted data rows
+	stats        outIDCounter          // Objects saved and errored by outID
+	errors       []error               // List of errors
+	log          *logs.OSRLog          // Saver log
+	ctx          context.Context       // Context of the process using the saver
+}
+
+// LocalFile defines a specific file where to save the objects. When the file is rotated,
+// the current file is closed, and the next one is opened when a new row is written.
+type LocalFile struct {
+	config     *SFTPFileConfig // Configuration of the file
+	part       int             // Number of the current file, starting at 0
+	rows       int             // Number of rows written on the current file
+	file       *os.File        // Current file, or nil if it's not open
+	compressor *gzip.Writer    // Gzip writer over the file, if the saver uses gzip
+	writer     rowWriter       // Writer of the rows, on the saver format
+}
+
+// rowWriter writes rows on a file format.
+type rowWriter interface {
+	// write writes the values of the fields of a row.
+	write(fields []string, values map[string]interface{}) error
+	// flush writes the buffered rows.
+	flush() error
+	// close writes the buffered rows and the end of the file, if the format has one.
+	close() error
+}
+
+// New uses the configuration defined in a LocalConfig to create a new instance.
+func (config *LocalConfig) New(name string, params utils.Params) (*LocalSaver, error) {
+	config = config.Format(params)
+	if config.Path == "" {
+		return nil, fmt.Errorf("local saver path not defined")
+	}
+	switch config.FileType {
+	case "":
+		config.FileType = query.CSV
+	case query.CSV, query.JSONL, query.Parquet:
+	default:
+		return nil, fmt.Errorf("unknown local saver file type: %s", config.FileType)
+	}
+	if config.FileType == query.Parquet && config.Append {
+		return nil, fmt.Errorf("parquet files cannot be appended")
+	}
+	if config.RotateRows > 0 && config.Append {
+		return nil, fmt.Errorf("rotated files cannot be appended")
+	}
+	log, err := logs.NewLog(name)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalSaver{
+		LocalConfig: config,
+		name:        name,
+		finished:    make(chan bool, 1),
+		objects:     make(chan Savable),
+		errors:      make([]error, 0),
+		outFiles:    make(map[string]*LocalFile, 0),
+		inserted:    0,
+		log:         log,
+	}, nil
+}
+
+// Format returns a copy of the configuration, formatted with the params defined in the task.
+func (config *LocalConfig) Format(params utils.Params) *LocalConfig {
+	newConfig := &LocalConfig{
+		Path:       params.FormatString(config.Path),
+		FileType:   strings.ToLower(params.FormatString(config.FileType)),
+		Gzip:       config.Gzip,
+		RotateRows: config.RotateRows,
+		ForceFlush: config.ForceFlush,
+		FileConfig: make(map[string]*SFTPFileConfig),
+		Append:     config.Append,
+	}
+	for k, v := range config.FileConfig {
+		newConfig.FileConfig[params.FormatString(k)] = v.Format(params)
+	}
+	return newConfig
+}
+
+func (saver *LocalSaver) Start(ctx context.Context) error {
+	saver.ctx = ctx
+	if err := os.MkdirAll(saver.Path, 0755); err != nil {
+		return err
+	}
+	for outName, outConfig := range saver.FileConfig {
+		if err := saver.createOutFile(outName, outConfig); err != nil {
+			saver.closeFiles()
+			return err
+		}
+	}
+	go func() {
+		for newObject := range saver.objects {
+			saver.writeToFile(newObject)
+		}
+		saver.finished <- true
+	}()
+	return nil
+}
+
+// There are no messages (yet) for this saver
+func (saver *LocalSaver) SendMessage(msg interface{}) error {
+	return nil
+}
+
+func (saver *LocalSaver) Save(objs ...interface{}) error {
+	if saver.ctx != nil && saver.ctx.Err() != nil {
+		return saver.ctx.Err()
+	}
+	for _, obj := range objs {
+		switch obj.(type) {
+		case Savable:
+			saver.objects <- obj.(Savable)
+		default:
+			saver.objects <- Savable{Object: obj}
+		}
+	}
+	return nil
+}
+
+// Finish closes all the files, returning the first error closing them.
+func (saver *LocalSaver) Finish() error {
+	close(saver.objects)
+	<-saver.finished
+	return saver.closeFiles()
+}
+
+// closeFiles closes the open files, returning the first error.
+func (saver *LocalSaver) closeFiles() (err error) {
+	for outID, outFile := range saver.outFiles {
+		if closeErr := saver.closeFile(outID, outFile); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
+
+// closeFile closes the current file of an outID. If it cannot be closed, the rows written on it
+// may be incomplete, so they are counted as errors.
+func (saver *LocalSaver) closeFile(outID string, file *LocalFile) error {
+	rows := file.rows
+	if err := file.close(); err != nil {
+		saver.log.WithFields(logrus.Fields{
+			"outID":  outID,
+			"number": rows,
+		}).Errorf("Couldn't close file: %s", err)
+		saver.errors = append(saver.errors, err)
+		saver.stats.discard(outID, rows)
+		return err
+	}
+	return nil
+}
+
+func (saver *LocalSaver) GetErrors() []error {
+	return saver.errors
+}
+
+func (saver *LocalSaver) GetInserted() int {
+	return saver.inserted
+}
+
+func (saver *LocalSaver) GetStats() models.SaverStats {
+	return saver.stats.copy()
+}
+
+func (saver *LocalSaver) GetAttachments() []string {
+	return []string{saver.log.Path}
+}
+
+func (saver *LocalSaver) GetName() string {
+	return saver.name
+}
+
+func (saver *LocalSaver) writeToFile(savable Savable) {
+	outID := savable.GetOutID()
+	file, ok := saver.outFiles[outID]
+	if !ok {
+		structName := savable.StructName()
+		file, ok = saver.outFiles[structName]
+		if ok {
+			outID = structName
+		} else {
+			saver.FileConfig[outID] = &SFTPFileConfig{
+				FileName: strings.Replace(outID, "/", "-", -1) + saver.extension(),
+				Append:   saver.Append,
+				Fields:   savable.FieldNames(), // all the fields
+			}
+			if err := saver.createOutFile(outID, saver.FileConfig[outID]); err != nil {
+				saver.addError(outID, fmt.Errorf("object could not be saved. The file didn't exist and it was impossible to create it: %s", err))
+				return
+			}
+			// It should exist now
+			file = saver.outFiles[outID]
+		}
+	}
+	if file.file == nil {
+		if err := saver.openPart(file); err != nil {
+			saver.addError(outID, fmt.Errorf("object could not be saved. Couldn't open the next file: %s", err))
+			return
+		}
+	}
+	if err := file.writer.write(file.config.Fields, savable.Fields()); err != nil {
+		saver.addError(outID, err)
+		return
+	}
+	if saver.ForceFlush {
+		if err := file.flush(); err != nil {
+			saver.addError(outID, err)
+		}
+	}
+	saver.inserted++
+	saver.stats.addInserted(outID, 1)
+	file.rows++
+	if saver.RotateRows > 0 && file.rows >= saver.RotateRows {
+		_ = saver.closeFile(outID, file)
+		file.part++
+	}
+}
+
+// addError stores an error which made an object of an outID not to be saved.
+func (saver *LocalSaver) addError(outID string, err error) {
+	saver.log.WithFields(logrus.Fields{
+		"outID": outID,
+	}).Errorf("Couldn't save object: %s", err)
+	saver.errors = append(saver.errors, err)
+	saver.stats.addErrors(outID, 1)
+}
+
+// extension returns the extension of the files created for the outIDs without config.
+func (saver *LocalSaver) extension() string {
+	ext, _ := query.Extension(saver.FileType)
+	if saver.Gzip && saver.FileType != query.Parquet {
+		ext += ".gz"
+	}
+	return ext
+}
+
+func (saver *LocalSaver) createOutFile(name string, config *SFTPFileConfig) error {
+	if len(config.Fields) == 0 {
+		return fmt.Errorf("must declare fields to write on file")
+	}
+	if config.Append && saver.FileType == query.Parquet {
+		return fmt.Errorf("parquet files cannot be appended")
+	}
+	if config.Append && saver.RotateRows > 0 {
+		// The rows of the existing parts are unknown, so the rotation would start again on the first part
+		return fmt.Errorf("rotated files cannot be appended")
+	}
+	file := &LocalFile{
+		config: config,
+	}
+	if err := saver.openPart(file); err != nil {
+		return err
+	}
+	saver.outFiles[name] = file
+	return nil
+}
+
+// openPart opens the current file of a LocalFile, writing its header if it's not appended.
+func (saver *LocalSaver) openPart(file *LocalFile) error {
+	path := filepath.Join(saver.Path, partName(file.config.FileName, file.part))
+	if saver.Gzip && saver.FileType != query.Parquet && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if file.config.Append {
+		// Append on it if it exists. Gzip files can be appended, because concatenated gzip members are a valid gzip file
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	osFile, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	var out io.Writer = osFile
+	var compressor *gzip.Writer
+	if saver.Gzip && saver.FileType != query.Parquet {
+		compressor = gzip.NewWriter(osFile)
+		out = compressor
+	}
+	var rows rowWriter
+	switch saver.FileType {
+	case query.JSONL:
+		rows = newJSONLRowWriter(out)
+	case query.Parquet:
+		rows, err = newParquetRowWriter(out, file.config.Fields, saver.Gzip)
+	default:
+		rows, err = newCSVRowWriter(out, file.config.Fields, !file.config.Append)
+	}
+	if err != nil {
+		_ = osFile.Close()
+		return err
+	}
+	saver.log.WithFields(logrus.Fields{
+		"path":   path,
+		"append": file.config.Append,
+	}).Info("Writing file...")
+	file.file = osFile
+	file.compressor = compressor
+	file.writer = rows
+	file.rows = 0
+	return nil
+}
+
+// partName returns the name of a part of a rotated file, adding "-n" before the extensions of the name.
+// The first part keeps the name.
+func partName(name string, part int) string {
+	if part == 0 {
+		return name
+	}
+	dir, base := filepath.Split(name)
+	if i := strings.Index(base, "."); i > 0 {
+		return fmt.Sprintf("%s%s-%d%s", dir, base[:i], part, base[i:])
+	}
+	return fmt.Sprintf("%s-%d", name, part)
+}
+
+// flush writes the buffered rows of the file.
+func (file *LocalFile) flush() error {
+	if err := file.writer.flush(); err != nil {
+		return err
+	}
+	if file.compressor != nil {
+		return file.compressor.Flush()
+	}
+	return nil
+}
+
+// close closes the current file of the LocalFile, if it's open.
+func (file *LocalFile) close() error {
+	if file.file == nil {
+		return nil
+	}
+	err := file.writer.close()
+	if file.compressor != nil {
+		if closeErr := file.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := file.file.Close(); err == nil {
+		err = closeErr
+	}
+	file.file = nil
+	file.compressor = nil
+	file.writer = nil
+	return err
+}
+
+// csvRowWriter writes rows as CSV lines.
+type csvRowWriter struct {
+	*csv.Writer
+}
+
+// newCSVRowWriter returns a CSV row writer, writing the fields as header if header is true.
+func newCSVRowWriter(out io.Writer, fields []string, header bool) (*csvRowWriter, error) {
+	rows := &csvRowWriter{csv.NewWriter(out)}
+	if header {
+		if err := rows.Write(fields); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+func (rows *csvRowWriter) write(fields []string, values map[string]interface{}) error {
+	return rows.Write(stringValues(fields, values))
+}
+
+func (rows *csvRowWriter) flush() error {
+	rows.Flush()
+	return rows.Error()
+}
+
+func (rows *csvRowWriter) close() error {
+	return rows.flush()
+}
+
+// jsonlRowWriter writes rows as JSON objects, one per line.
+type jsonlRowWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+// newJSONLRowWriter returns a JSON Lines row writer.
+func newJSONLRowWriter(out io.Writer) *jsonlRowWriter {
+	buffer := bufio.NewWriter(out)
+	return &jsonlRowWriter{
+		buffer:  buffer,
+		encoder: json.NewEncoder(buffer),
+	}
+}
+
+// write writes the fields as a JSON object. Values keep their JSON representation, so numbers and booleans are not quoted.
+func (rows *jsonlRowWriter) write(fields []string, values map[string]interface{}) error {
+	row := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		row[field] = values[field]
+	}
+	return rows.encoder.Encode(row)
+}
+
+func (rows *jsonlRowWriter) flush() error {
+	return rows.buffer.Flush()
+}
+
+func (rows *jsonlRowWriter) close() error {
+	return rows.flush()
+}
+
+// parquetRowWriter writes rows on a Parquet file, with an optional string column per field.
+type parquetRowWriter struct {
+	*writer.CSVWriter
+}
+
+// newParquetRowWriter returns a Parquet row writer, using gzip or snappy compression.
+func newParquetRowWriter(out io.Writer, fields []string, gzip bool) (*parquetRowWriter, error) {
+	metadata := make([]string, len(fields))
+	for i, field := range fields {
+		column, err := query.ParquetField(field, query.ParquetString)
+		if err != nil {
+			return nil, err
+		}
+		metadata[i] = column
+	}
+	parquetWriter, err := writer.NewCSVWriterFromWriter(metadata, out, 1)
+	if err != nil {
+		return nil, err
+	}
+	parquetWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+	if gzip {
+		parquetWriter.CompressionType = parquet.CompressionCodec_GZIP
+	}
+	return &parquetRowWriter{parquetWriter}, nil
+}
+
+func (rows *parquetRowWriter) write(fields []string, values map[string]interface{}) error {
+	row := make([]interface{}, len(fields))
+	for i, value := range stringValues(fields, values) {
+		row[i] = value
+	}
+	return rows.Write(row)
+}
+
+// flush does nothing, because Parquet rows are written by row groups.
+func (rows *parquetRowWriter) flush() error {
+	return nil
+}
+
+func (rows *parquetRowWriter) close() error {
+	return rows.WriteStop()
+}
+
+// stringValues returns the values of the fields formatted as strings, like the SFTP saver.
+// Missing fields are empty.
+func stringValues(fields []string, values map[string]interface{}) []string {
+	strValues := make([]string, len(fields))
+	for i, field := range fields {
+		if value, ok := values[field]; ok {
+			strValues[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	return strValues
+}
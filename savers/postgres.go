@@ -1,14 +1,17 @@
 package savers
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/clcert/osr/databases"
 	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
 	"github.com/clcert/osr/utils"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,6 +21,9 @@ type PostgresConfig struct {
 	InsertTimeout time.Duration            // Number of seconds to wait to autocommit if there are no new objects received.
 	Mutex         bool                     // Use a mutex to insert items.
 	InsertConfig  map[string]*InsertConfig // Config specific for each object or outID
+	Copy          bool                     // If true, objects are streamed with COPY FROM STDIN to a staging table, and then merged into their table.
+	CopyFormat    string                   // Format of COPY on copy mode: csv (default) or binary.
+	Atomic        bool                     // If true, all the objects of the process are saved on a transaction, committed only if the process succeeds.
 }
 
 // InsertConfig defines a configuration related to a specific type of object, to being saved
@@ -29,17 +35,22 @@ type InsertConfig struct {
 
 // PostgresSaver defines a saver structure that connects with the default Postgres database.
 type PostgresSaver struct {
-	*PostgresConfig                          // Configuration related to the saver
-	name            string                   // Name for the saver instance
-	chanMutex       sync.Mutex               // Mutex to edit maps concurrently
-	insertMutex     sync.Mutex               // Mutex for inserting if Config has mutex = true
-	db              *pg.DB                   // Pointer to Postgres DB connection
-	wg              sync.WaitGroup           // Wait Group to wait the finish of all channels
-	channels        map[string]chan Savable  // A map of channels identified by the outID of the objects
-	closeSignals    map[string]chan struct{} // A map of channels identified by the outID of the objects, signaling close
-	inserted        int                      // Number of inserted elements (FYI)
-	errors          []error                  // List of errors
-	log             *logs.OSRLog             // Saver log
+	*PostgresConfig                                    // Configuration related to the saver
+	name            string                             // Name for the saver instance
+	chanMutex       sync.Mutex                         // Mutex to edit maps concurrently
+	insertMutex     sync.Mutex                         // Mutex for inserting if Config has mutex = true
+	db              *pg.DB                             // Pointer to Postgres DB connection
+	wg              sync.WaitGroup                     // Wait Group to wait the finish of all channels
+	channels        map[string]chan Savable            // A map of channels identified by the outID of the objects
+	closeSignals    map[string]chan struct{}           // A map of channels identified by the outID of the objects, signaling close
+	columns         map[string]map[string]*tableColumn // Columns of the tables used on copy mode
+	tx              *pg.Tx                             // Transaction of the process, on atomic mode
+	txMutex         sync.Mutex                         // Serializes the use of the transaction
+	inserted        int                                // Number of inserted elements (FYI)
+	stats           outIDCounter                       // Objects saved and errored by outID
+	errors          []error                            // List of errors
+	log             *logs.OSRLog                       // Saver log
+	ctx             context.Context                    // Context of the process using the saver
 	i               int
 }
 
@@ -55,8 +66,19 @@ func (config *PostgresConfig) New(name string, params utils.Params) (*PostgresSa
 	if config.InsertTimeout == 0 {
 		config.InsertTimeout = 10 // default timeout
 	}
+	switch config.CopyFormat {
+	case "":
+		config.CopyFormat = CopyCSV
+	case CopyCSV, CopyBinary:
+	default:
+		return nil, fmt.Errorf("unknown copy format: %s", config.CopyFormat)
+	}
 	if config.Buffer == 0 {
-		config.Buffer = 1024 // Default buffer
+		if config.Copy {
+			config.Buffer = DefaultCopyBuffer
+		} else {
+			config.Buffer = 1024 // Default buffer
+		}
 	}
 	log, err := logs.NewLog(name)
 	if err != nil {
@@ -67,6 +89,7 @@ func (config *PostgresConfig) New(name string, params utils.Params) (*PostgresSa
 		PostgresConfig: config,
 		channels:       make(map[string]chan Savable),
 		closeSignals:   make(map[string]chan struct{}),
+		columns:        make(map[string]map[string]*tableColumn),
 		errors:         make([]error, 0),
 		inserted:       0,
 		log:            log,
@@ -85,12 +108,21 @@ func (config *PostgresConfig) Format(params utils.Params) error {
 	return nil
 }
 
-func (saver *PostgresSaver) Start() error {
+func (saver *PostgresSaver) Start(ctx context.Context) error {
+	saver.ctx = ctx
 	db, err := databases.GetPostgresWriter()
 	if err != nil {
 		return err
 	}
 	saver.db = db
+	if saver.Atomic {
+		tx, err := db.Begin()
+		if err != nil {
+			_ = db.Close()
+			return err
+		}
+		saver.tx = tx
+	}
 	return nil
 }
 
@@ -117,6 +149,9 @@ func (saver *PostgresSaver) SendMessage(msg interface{}) error {
 }
 
 func (saver *PostgresSaver) Save(objs ...interface{}) error {
+	if saver.ctx != nil && saver.ctx.Err() != nil {
+		return saver.ctx.Err()
+	}
 	for _, obj := range objs {
 		var savable Savable
 		switch obj.(type) {
@@ -138,13 +173,54 @@ func (saver *PostgresSaver) Finish() error {
 	}
 	saver.chanMutex.Unlock()
 	saver.wg.Wait()
+	if saver.tx != nil {
+		// The connection is closed when the transaction is committed or rolled back
+		return nil
+	}
 	return saver.db.Close()
 }
 
+// IsAtomic returns true if the saver uses a transaction for all the objects of the process.
+func (saver *PostgresSaver) IsAtomic() bool {
+	return saver.tx != nil
+}
+
+// Commit commits the transaction of the process and closes the connection, on atomic mode.
+func (saver *PostgresSaver) Commit() error {
+	if saver.tx == nil {
+		return nil
+	}
+	defer saver.db.Close()
+	saver.log.WithFields(logrus.Fields{
+		"inserted": saver.inserted,
+	}).Info("Committing process transaction...")
+	return saver.tx.Commit()
+}
+
+// Rollback discards the transaction of the process and closes the connection, on atomic mode.
+func (saver *PostgresSaver) Rollback() error {
+	if saver.tx == nil {
+		return nil
+	}
+	defer saver.db.Close()
+	saver.log.WithFields(logrus.Fields{
+		"discarded": saver.inserted,
+	}).Warn("Rolling back process transaction...")
+	return saver.tx.Rollback()
+}
+
 func (saver *PostgresSaver) GetErrors() []error {
 	return saver.errors
 }
 
+func (saver *PostgresSaver) GetInserted() int {
+	return saver.inserted
+}
+
+func (saver *PostgresSaver) GetStats() models.SaverStats {
+	return saver.stats.copy()
+}
+
 // TODO: add queries as log
 func (saver *PostgresSaver) GetAttachments() []string {
 	return []string{saver.log.Path}
@@ -181,7 +257,7 @@ L:
 			}
 			objectList = append(objectList, newObject.Object)
 			if len(objectList) >= saver.Buffer {
-				saver.insertToDatabase(objectList, config)
+				saver.insertToDatabase(name, objectList, config)
 				objectList = make([]interface{}, 0, saver.Buffer)
 			}
 		case <-time.After(saver.InsertTimeout * time.Second):
@@ -192,7 +268,7 @@ L:
 					"mutex":    saver.Mutex,
 					"timeout":  saver.InsertTimeout,
 				}).Info("No object received in some time, saving...")
-				saver.insertToDatabase(objectList, config)
+				saver.insertToDatabase(name, objectList, config)
 				objectList = make([]interface{}, 0, saver.Buffer)
 			}
 		case <-closeSignal:
@@ -200,7 +276,7 @@ L:
 		}
 	}
 
-	saver.insertToDatabase(objectList, config)
+	saver.insertToDatabase(name, objectList, config)
 	saver.log.WithFields(logrus.Fields{
 		"outID": name,
 	}).Info("Done, deleting saver branch...")
@@ -210,9 +286,14 @@ L:
 	saver.wg.Done()
 }
 
-func (saver *PostgresSaver) insertToDatabase(objList []interface{}, config *InsertConfig) {
+func (saver *PostgresSaver) insertToDatabase(outID string, objList []interface{}, config *InsertConfig) {
 	if len(objList) > 0 {
-		query := saver.db.Model(objList...)
+		var query *orm.Query
+		if saver.tx != nil {
+			query = saver.tx.Model(objList...)
+		} else {
+			query = saver.db.Model(objList...)
+		}
 		if len(config.OnConflict) > 0 {
 			query = query.OnConflict(config.OnConflict)
 			for _, setOption := range config.Set {
@@ -228,13 +309,19 @@ func (saver *PostgresSaver) insertToDatabase(objList []interface{}, config *Inse
 			saver.insertMutex.Lock()
 			defer saver.insertMutex.Unlock()
 		}
+		if saver.tx != nil {
+			saver.txMutex.Lock()
+			defer saver.txMutex.Unlock()
+		}
 		result, err := query.Insert()
 		if err != nil {
 			saver.log.Errorf("Couldn't insert entries to database: %v", err)
 			saver.errors = append(saver.errors, err)
+			saver.stats.addErrors(outID, len(objList))
 			return
 		}
 		saver.inserted += result.RowsAffected()
+		saver.stats.addInserted(outID, result.RowsAffected())
 	}
 }
 
@@ -247,7 +334,11 @@ func (saver *PostgresSaver) getChannel(name string) chan<- Savable {
 	}
 	saver.channels[name] = make(chan Savable)
 	saver.closeSignals[name] = make(chan struct{})
-	go saver.startChannel(name)
+	if saver.Copy {
+		go saver.copyChannel(name)
+	} else {
+		go saver.startChannel(name)
+	}
 	return saver.channels[name]
 }
 
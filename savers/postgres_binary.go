@@ -0,0 +1,308 @@
+package savers
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-pg/pg/v10/types"
+)
+
+// Formats of COPY on copy mode.
+const (
+	CopyCSV    = "csv"    // Rows are written as CSV, using the same text values as INSERT
+	CopyBinary = "binary" // Rows are written in the binary format of COPY
+)
+
+var (
+	// binaryHeader starts the data of a COPY in binary format: the signature, the flags and the length of the header extension.
+	binaryHeader = []byte("PGCOPY\n\377\r\n\000\000\000\000\000\000\000\000\000")
+	// binaryTrailer ends the data of a COPY in binary format.
+	binaryTrailer = []byte{0xff, 0xff}
+	// binaryEpoch is the origin of the Postgres timestamps, in microseconds since the Unix epoch.
+	binaryEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Unix() * 1000000
+
+	valueAppenderType = reflect.TypeOf((*types.ValueAppender)(nil)).Elem()
+	driverValuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	bytesType         = reflect.TypeOf([]byte(nil))
+	timeType          = reflect.TypeOf(time.Time{})
+	ipType            = reflect.TypeOf(net.IP(nil))
+	ipNetType         = reflect.TypeOf(net.IPNet{})
+)
+
+// Family codes of inet and cidr values in binary format.
+const (
+	binaryInet4 = 2
+	binaryInet6 = 3
+)
+
+// binaryEncoder appends the binary representation of a value, which is never a nil pointer, to a row.
+type binaryEncoder func(b []byte, v reflect.Value) ([]byte, error)
+
+// copyField is a column copied in binary format. Columns without a binary encoder for their type are
+// copied as text to the staging table, and cast to the type of the target column when they are merged.
+type copyField struct {
+	field   *orm.Field    // Field of the model
+	encoder binaryEncoder // Encoder of the values, or nil if they are copied as text
+	cast    string        // Type of the target column, if the values are copied as text
+}
+
+// newCopyFields returns the fields of a table, with the encoders for the types of their target columns.
+func newCopyFields(table *orm.Table, columns map[string]*tableColumn) ([]*copyField, error) {
+	fields := make([]*copyField, len(table.Fields))
+	for i, field := range table.Fields {
+		column, ok := columns[field.SQLName]
+		if !ok {
+			return nil, fmt.Errorf("column %s not found in %s", field.SQLName, table.SQLName)
+		}
+		fields[i] = &copyField{field: field, encoder: binaryEncoderFor(column.TypeName, field.Type)}
+		if fields[i].encoder == nil {
+			fields[i].cast = column.Type
+		}
+	}
+	return fields, nil
+}
+
+// binaryEncoderFor returns the encoder of the values of a Go type for a Postgres type, or nil if there is no
+// encoder for them. Types with their own go-pg or database/sql conversion are never encoded, because their
+// binary representation can be different from the one of their kind.
+func binaryEncoderFor(typeName string, typ reflect.Type) binaryEncoder {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	for _, iface := range []reflect.Type{valueAppenderType, driverValuerType} {
+		if typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface) {
+			return nil
+		}
+	}
+	kind := typ.Kind()
+	switch typeName {
+	case "bool":
+		if kind == reflect.Bool {
+			return appendBinaryBool
+		}
+	case "int2":
+		if isIntKind(kind) {
+			return binaryIntEncoder(2)
+		}
+	case "int4":
+		if isIntKind(kind) {
+			return binaryIntEncoder(4)
+		}
+	case "int8":
+		if isIntKind(kind) {
+			return binaryIntEncoder(8)
+		}
+	case "float4":
+		if kind == reflect.Float32 || kind == reflect.Float64 {
+			return appendBinaryFloat4
+		}
+	case "float8":
+		if kind == reflect.Float32 || kind == reflect.Float64 {
+			return appendBinaryFloat8
+		}
+	case "text", "varchar":
+		if kind == reflect.String {
+			return appendBinaryString
+		}
+	case "bytea":
+		if typ == bytesType {
+			return appendBinaryBytes
+		}
+	case "timestamptz":
+		if typ == timeType {
+			return appendBinaryTimestamp
+		}
+	case "inet":
+		if typ == ipType {
+			return appendBinaryIP
+		}
+		if typ == ipNetType {
+			return binaryIPNetEncoder(false)
+		}
+	case "cidr":
+		if typ == ipNetType {
+			return binaryIPNetEncoder(true)
+		}
+	}
+	return nil
+}
+
+// isIntKind returns true if the kind is a signed or unsigned integer.
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// appendUint appends the size lowest bytes of a number in network byte order.
+func appendUint(b []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(uint(i)*8)))
+	}
+	return b
+}
+
+func appendBinaryBool(b []byte, v reflect.Value) ([]byte, error) {
+	if v.Bool() {
+		return append(b, 1), nil
+	}
+	return append(b, 0), nil
+}
+
+// binaryIntEncoder returns an encoder of integers of size bytes, which fails if the values are out of their range.
+func binaryIntEncoder(size int) binaryEncoder {
+	max := int64(1)<<(uint(size)*8-1) - 1
+	min := -max - 1
+	return func(b []byte, v reflect.Value) ([]byte, error) {
+		var n int64
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.Uint() > math.MaxInt64 {
+				return nil, fmt.Errorf("value %d out of range for a %d bytes integer", v.Uint(), size)
+			}
+			n = int64(v.Uint())
+		default:
+			n = v.Int()
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("value %d out of range for a %d bytes integer", n, size)
+		}
+		return appendUint(b, uint64(n), size), nil
+	}
+}
+
+func appendBinaryFloat4(b []byte, v reflect.Value) ([]byte, error) {
+	return appendUint(b, uint64(math.Float32bits(float32(v.Float()))), 4), nil
+}
+
+func appendBinaryFloat8(b []byte, v reflect.Value) ([]byte, error) {
+	return appendUint(b, math.Float64bits(v.Float()), 8), nil
+}
+
+func appendBinaryString(b []byte, v reflect.Value) ([]byte, error) {
+	return append(b, v.String()...), nil
+}
+
+func appendBinaryBytes(b []byte, v reflect.Value) ([]byte, error) {
+	return append(b, v.Bytes()...), nil
+}
+
+// appendBinaryTimestamp appends a time as the number of microseconds since the Postgres epoch.
+func appendBinaryTimestamp(b []byte, v reflect.Value) ([]byte, error) {
+	tm := v.Interface().(time.Time)
+	micros := tm.Unix()*1000000 + int64(tm.Nanosecond()/1000) - binaryEpoch
+	return appendUint(b, uint64(micros), 8), nil
+}
+
+// appendBinaryInet appends an address with its family, the bits of its netmask and its bytes.
+func appendBinaryInet(b []byte, ip net.IP, bits int, cidr bool) ([]byte, error) {
+	family := byte(binaryInet6)
+	if len(ip) == net.IPv4len {
+		family = binaryInet4
+	}
+	isCIDR := byte(0)
+	if cidr {
+		isCIDR = 1
+	}
+	b = append(b, family, byte(bits), isCIDR, byte(len(ip)))
+	return append(b, ip...), nil
+}
+
+func appendBinaryIP(b []byte, v reflect.Value) ([]byte, error) {
+	ip := v.Interface().(net.IP)
+	if ip4 := ip.To4(); ip4 != nil {
+		return appendBinaryInet(b, ip4, 32, false)
+	}
+	if len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("invalid IP address: %v", []byte(ip))
+	}
+	return appendBinaryInet(b, ip, 128, false)
+}
+
+// binaryIPNetEncoder returns an encoder of networks for inet columns, or for cidr columns if cidr is true.
+func binaryIPNetEncoder(cidr bool) binaryEncoder {
+	return func(b []byte, v reflect.Value) ([]byte, error) {
+		ipNet := v.Interface().(net.IPNet)
+		ones, bits := ipNet.Mask.Size()
+		ip := ipNet.IP.To16()
+		if bits == 8*net.IPv4len {
+			ip = ipNet.IP.To4()
+		}
+		if ip == nil || bits == 0 {
+			return nil, fmt.Errorf("invalid network: %s", ipNet.String())
+		}
+		return appendBinaryInet(b, ip, ones, cidr)
+	}
+}
+
+// fieldValue returns the value of a field of a struct, or false if it must be copied as NULL. Like ORM inserts,
+// zero values are NULL unless the field has use_zero, and zero values of fields with defaults are NULL too,
+// so they are replaced by the defaults when they are merged.
+func fieldValue(field *orm.Field, strct reflect.Value) (reflect.Value, bool) {
+	if field.HasZeroValue(strct) && (field.NullZero() || field.Default != "") {
+		return reflect.Value{}, false
+	}
+	value := strct
+	for _, index := range field.Index {
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(index)
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return reflect.Value{}, false
+		}
+		value = value.Elem()
+	}
+	return value, true
+}
+
+// appendBinaryRow appends a struct as a row in binary format: the number of fields, and the length and the
+// value of each field, with length -1 for NULL values.
+func appendBinaryRow(row []byte, fields []*copyField, strct reflect.Value) ([]byte, error) {
+	row = appendUint(row, uint64(len(fields)), 2)
+	for _, copyField := range fields {
+		start := len(row)
+		row = append(row, 0, 0, 0, 0)
+		if copyField.encoder == nil {
+			if copyField.field.Default != "" && copyField.field.HasZeroValue(strct) {
+				row = appendUint(row[:start], math.MaxUint32, 4)
+				continue
+			}
+			// go-pg appends nothing and returns nil for NULL values
+			value := copyField.field.AppendValue(row, strct, 0)
+			if value == nil {
+				row = appendUint(row[:start], math.MaxUint32, 4)
+				continue
+			}
+			row = value
+		} else {
+			value, ok := fieldValue(copyField.field, strct)
+			if !ok {
+				row = appendUint(row[:start], math.MaxUint32, 4)
+				continue
+			}
+			var err error
+			row, err = copyField.encoder(row, value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot encode %s: %s", copyField.field.SQLName, err)
+			}
+		}
+		binary.BigEndian.PutUint32(row[start:], uint32(len(row)-start-4))
+	}
+	return row, nil
+}
@@ -0,0 +1,154 @@
+package savers
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// binaryTestStatus is a type with its own database conversion.
+type binaryTestStatus int
+
+func (status binaryTestStatus) Value() (driver.Value, error) {
+	return []string{"open", "closed"}[status], nil
+}
+
+type binaryTestRow struct {
+	ID     int64
+	Port   int `pg:",use_zero"`
+	Open   bool
+	Name   string
+	Score  float64
+	IP     net.IP
+	Subnet *net.IPNet
+	Date   time.Time `pg:",use_zero"`
+	Status binaryTestStatus
+	Added  time.Time `pg:"default:now()"`
+}
+
+var binaryTestColumns = map[string]*tableColumn{
+	"id":     {Type: "bigint", TypeName: "int8"},
+	"port":   {Type: "smallint", TypeName: "int2"},
+	"open":   {Type: "boolean", TypeName: "bool"},
+	"name":   {Type: "text", TypeName: "text"},
+	"score":  {Type: "real", TypeName: "float4"},
+	"ip":     {Type: "inet", TypeName: "inet"},
+	"subnet": {Type: "cidr", TypeName: "cidr"},
+	"date":   {Type: "timestamp with time zone", TypeName: "timestamptz"},
+	"status": {Type: "osr_status", TypeName: "osr_status"},
+	"added":  {Type: "timestamp with time zone", TypeName: "timestamptz", ColumnDefault: "now()"},
+}
+
+// binaryField returns a field of a binary row: its length and its value.
+func binaryField(value ...byte) []byte {
+	return append(appendUint(nil, uint64(len(value)), 4), value...)
+}
+
+var binaryNull = []byte{0xff, 0xff, 0xff, 0xff}
+
+func TestAppendBinaryRow(t *testing.T) {
+	table := orm.GetTable(reflect.TypeOf(binaryTestRow{}))
+	fields, err := newCopyFields(table, binaryTestColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range fields {
+		if (field.encoder == nil) != (field.field.SQLName == "status") {
+			t.Errorf("unexpected encoder for %s: only status should be copied as text", field.field.SQLName)
+		}
+	}
+	if fields[8].cast != "osr_status" {
+		t.Errorf("expected status to be cast to osr_status, got %q", fields[8].cast)
+	}
+	_, subnet, _ := net.ParseCIDR("192.0.2.0/24")
+	tests := []struct {
+		name   string
+		row    *binaryTestRow
+		fields [][]byte
+	}{
+		{
+			name: "values",
+			row: &binaryTestRow{
+				ID:     -2,
+				Port:   443,
+				Open:   true,
+				Name:   "https",
+				Score:  0.5,
+				IP:     net.ParseIP("2001:db8::1"),
+				Subnet: subnet,
+				Date:   time.Date(2000, 1, 1, 0, 0, 1, 500000, time.FixedZone("CLT", -3*3600)),
+				Status: 1,
+				Added:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			fields: [][]byte{
+				binaryField(0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe),
+				binaryField(0x01, 0xbb),
+				binaryField(1),
+				binaryField([]byte("https")...),
+				binaryField(0x3f, 0x00, 0x00, 0x00),
+				binaryField(3, 128, 0, 16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1),
+				binaryField(2, 24, 1, 4, 192, 0, 2, 0),
+				binaryField(0, 0, 0, 0x02, 0x83, 0xca, 0x30, 0x34),
+				binaryField([]byte("closed")...),
+				binaryField(0x00, 0x02, 0xb0, 0xd5, 0xd4, 0xe9, 0x40, 0x00),
+			},
+		},
+		{
+			name: "zero values",
+			row:  &binaryTestRow{IP: net.ParseIP("192.0.2.1")},
+			fields: [][]byte{
+				binaryNull,
+				binaryField(0, 0),
+				binaryNull,
+				binaryNull,
+				binaryNull,
+				binaryField(2, 32, 0, 4, 192, 0, 2, 1),
+				binaryNull,
+				binaryField(0xff, 0x1f, 0xe2, 0xff, 0xc5, 0x9c, 0x60, 0x00),
+				binaryNull,
+				binaryNull,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			row, err := appendBinaryRow(nil, fields, reflect.ValueOf(test.row).Elem())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := append([]byte{0, byte(len(test.fields))}, bytes.Join(test.fields, nil)...)
+			if !bytes.Equal(row, expected) {
+				t.Errorf("expected row\n%x, got\n%x", expected, row)
+			}
+		})
+	}
+}
+
+func TestBinaryIntEncoderRange(t *testing.T) {
+	tests := []struct {
+		size  int
+		value interface{}
+		valid bool
+	}{
+		{2, int64(32767), true},
+		{2, int64(32768), false},
+		{2, int64(-32768), true},
+		{2, int64(-32769), false},
+		{4, uint32(1 << 31), false},
+		{8, uint64(1 << 63), false},
+		{8, int64(-1 << 63), true},
+	}
+	for _, test := range tests {
+		_, err := binaryIntEncoder(test.size)(nil, reflect.ValueOf(test.value))
+		if test.valid && err != nil {
+			t.Errorf("expected %v to fit in %d bytes, got %s", test.value, test.size, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("expected %v not to fit in %d bytes", test.value, test.size)
+		}
+	}
+}
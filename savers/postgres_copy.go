@@ -0,0 +1,439 @@
+package savers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/clcert/osr/utils"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultCopyBuffer is the default number of objects copied to a staging table before merging them, on copy mode.
+const DefaultCopyBuffer = 100000
+
+// copyBatch streams the objects of an outID to a staging table with COPY FROM STDIN, using CSV or binary format,
+// and merges them into the target table when it's committed. All the statements of a batch run on the same transaction,
+// which is the transaction of the saver on atomic mode.
+type copyBatch struct {
+	saver   *PostgresSaver // Saver of the batch
+	config  *InsertConfig  // Conflict handling config of the outID
+	table   *orm.Table     // Target table, defined by the type of the first object
+	fields  []*copyField   // Fields of the table and their encoders, on binary format
+	tx      *pg.Tx         // Transaction of the batch
+	staging string         // Name of the staging table
+	pipe    *io.PipeWriter // Pipe read by COPY
+	data    *bytes.Buffer  // Rows kept in memory, on atomic mode
+	writer  *bufio.Writer  // Buffered writer of the pipe
+	copied  chan error     // Receives the result of COPY when it ends
+	rows    int            // Number of objects written on the batch
+	row     []byte         // Buffer of the row being written
+}
+
+// copyChannel receives the objects of an outID, copying them to the database in batches.
+// It works like startChannel, but the objects are streamed instead of kept in memory.
+func (saver *PostgresSaver) copyChannel(name string) {
+	saver.wg.Add(1)
+	var batch *copyBatch
+	channel := saver.channels[name]
+	closeSignal := saver.closeSignals[name]
+L:
+	for {
+		select {
+		case newObject := <-channel:
+			if batch == nil {
+				var err error
+				batch, err = saver.newCopyBatch(newObject)
+				if err != nil {
+					saver.log.WithFields(logrus.Fields{
+						"outID": name,
+					}).Errorf("Couldn't start copy to database: %v", err)
+					saver.errors = append(saver.errors, err)
+					saver.stats.addErrors(name, 1)
+					continue
+				}
+			}
+			if err := batch.write(newObject.Object); err != nil {
+				saver.log.WithFields(logrus.Fields{
+					"outID": name,
+				}).Errorf("Couldn't copy object to database: %v", err)
+				saver.errors = append(saver.errors, err)
+				saver.stats.addErrors(name, 1)
+			}
+			if batch.rows >= saver.Buffer {
+				saver.commitBatch(name, batch)
+				batch = nil
+			}
+		case <-time.After(saver.InsertTimeout * time.Second):
+			if batch != nil {
+				saver.log.WithFields(logrus.Fields{
+					"number":   batch.rows,
+					"inserted": saver.inserted,
+					"mutex":    saver.Mutex,
+					"timeout":  saver.InsertTimeout,
+				}).Info("No object received in some time, saving...")
+				saver.commitBatch(name, batch)
+				batch = nil
+			}
+		case <-closeSignal:
+			break L
+		}
+	}
+	if batch != nil {
+		saver.commitBatch(name, batch)
+	}
+	saver.log.WithFields(logrus.Fields{
+		"outID": name,
+	}).Info("Done, deleting saver branch...")
+	saver.chanMutex.Lock()
+	delete(saver.channels, name)
+	saver.chanMutex.Unlock()
+	saver.wg.Done()
+}
+
+// newCopyBatch starts a transaction with a staging table for the type of an object, and starts copying to it.
+// On atomic mode, the batch uses the transaction of the saver, and its rows are kept in memory until it's committed,
+// so the transaction is used by one batch at a time.
+func (saver *PostgresSaver) newCopyBatch(object Savable) (*copyBatch, error) {
+	typ := reflect.Indirect(reflect.ValueOf(object.Object)).Type()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("copy mode needs struct objects, got %s", typ)
+	}
+	table := orm.GetTable(typ)
+	if len(table.Fields) == 0 {
+		return nil, fmt.Errorf("model %s has no fields", table.TypeName)
+	}
+	batch := &copyBatch{
+		saver:   saver,
+		config:  saver.getInsertConfig(object),
+		table:   table,
+		staging: "osr_staging_" + utils.GenerateRandomHex(8),
+		copied:  make(chan error, 1),
+	}
+	if saver.tx != nil {
+		batch.tx = saver.tx
+		saver.txMutex.Lock()
+		err := batch.loadFields()
+		saver.txMutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		batch.data = new(bytes.Buffer)
+		batch.writer = bufio.NewWriter(batch.data)
+		return batch, batch.writeHeader()
+	}
+	tx, err := saver.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	batch.tx = tx
+	if err := batch.loadFields(); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err := batch.createStaging(); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	batch.pipe = writer
+	batch.writer = bufio.NewWriter(writer)
+	if err := batch.writeHeader(); err != nil {
+		_ = writer.Close()
+		_ = tx.Rollback()
+		return nil, err
+	}
+	go func() {
+		_, err := tx.CopyFrom(reader, batch.copyStatement())
+		// Unblocks the writer if COPY failed before reading all the rows
+		_ = reader.CloseWithError(err)
+		batch.copied <- err
+	}()
+	return batch, nil
+}
+
+// loadFields reads the types of the columns of the target table and chooses the encoders of its fields, on binary format.
+func (batch *copyBatch) loadFields() error {
+	if batch.saver.CopyFormat != CopyBinary {
+		return nil
+	}
+	columns, err := batch.saver.getColumns(batch.tx, batch.table)
+	if err != nil {
+		return err
+	}
+	batch.fields, err = newCopyFields(batch.table, columns)
+	return err
+}
+
+// writeHeader writes the header of the rows, on binary format.
+func (batch *copyBatch) writeHeader() error {
+	if batch.fields == nil {
+		return nil
+	}
+	_, err := batch.writer.Write(binaryHeader)
+	return err
+}
+
+// end writes the end of the rows, on binary format, and flushes the writer.
+func (batch *copyBatch) end() error {
+	if batch.fields != nil {
+		if _, err := batch.writer.Write(binaryTrailer); err != nil {
+			return err
+		}
+	}
+	return batch.writer.Flush()
+}
+
+// createStaging creates the staging table of the batch. It has no constraints, so it accepts NULL values for the columns with defaults.
+// On binary format, the columns without an encoder for their type are created as text.
+func (batch *copyBatch) createStaging() error {
+	columns := batch.columns()
+	if batch.fields != nil {
+		stagingColumns := make([]string, len(batch.fields))
+		for i, field := range batch.fields {
+			stagingColumns[i] = string(field.field.Column)
+			if field.encoder == nil {
+				stagingColumns[i] = fmt.Sprintf("%s::text AS %s", field.field.Column, field.field.Column)
+			}
+		}
+		columns = strings.Join(stagingColumns, ", ")
+	}
+	_, err := batch.tx.Exec(fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+		batch.staging, columns, batch.table.SQLName))
+	return err
+}
+
+// copyStatement returns the COPY statement which fills the staging table.
+func (batch *copyBatch) copyStatement() string {
+	format := "CSV"
+	if batch.fields != nil {
+		format = "BINARY"
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN WITH %s", batch.staging, batch.columns(), format)
+}
+
+// columns returns the list of columns of the table.
+func (batch *copyBatch) columns() string {
+	columns := make([]string, len(batch.table.Fields))
+	for i, field := range batch.table.Fields {
+		columns[i] = string(field.Column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// write writes an object as a row, in binary format or as CSV.
+func (batch *copyBatch) write(object interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(object))
+	if value.Type() != batch.table.Type {
+		return fmt.Errorf("object of type %s cannot be copied to the table of %s", value.Type(), batch.table.TypeName)
+	}
+	var row []byte
+	if batch.fields != nil {
+		var err error
+		if row, err = appendBinaryRow(batch.row[:0], batch.fields, value); err != nil {
+			return err
+		}
+	} else {
+		row = batch.appendCSVRow(batch.row[:0], value)
+	}
+	batch.row = row
+	if _, err := batch.writer.Write(row); err != nil {
+		return err
+	}
+	batch.rows++
+	return nil
+}
+
+// appendCSVRow appends an object as a CSV row. NULL values are written as unquoted empty fields, and the other
+// values are always quoted, so empty strings are not confused with NULL.
+func (batch *copyBatch) appendCSVRow(row []byte, value reflect.Value) []byte {
+	for i, field := range batch.table.Fields {
+		if i > 0 {
+			row = append(row, ',')
+		}
+		// Like ORM inserts, zero values of fields with defaults are replaced by the defaults when they are merged
+		if field.Default != "" && field.HasZeroValue(value) {
+			continue
+		}
+		// go-pg appends nothing and returns nil for NULL values (including zero values of fields without use_zero)
+		fieldValue := field.AppendValue(make([]byte, 0, 16), value, 0)
+		if fieldValue == nil {
+			continue
+		}
+		row = append(row, '"')
+		for _, c := range fieldValue {
+			if c == '"' {
+				row = append(row, '"')
+			}
+			row = append(row, c)
+		}
+		row = append(row, '"')
+	}
+	return append(row, '\n')
+}
+
+// commitBatch ends the copy of a batch, merges the staging table into the target table and commits the transaction.
+func (saver *PostgresSaver) commitBatch(outID string, batch *copyBatch) {
+	saver.log.WithFields(logrus.Fields{
+		"number":   batch.rows,
+		"inserted": saver.inserted,
+		"mutex":    saver.Mutex,
+	}).Info("Merging copied entries into database...")
+	inserted, err := batch.commit()
+	if err != nil {
+		saver.log.Errorf("Couldn't copy entries to database: %v", err)
+		saver.errors = append(saver.errors, err)
+		saver.stats.addErrors(outID, batch.rows)
+		return
+	}
+	saver.inserted += inserted
+	saver.stats.addInserted(outID, inserted)
+}
+
+// commit ends the copy and merges the staging table into the target table, returning the number of rows inserted or updated.
+func (batch *copyBatch) commit() (int, error) {
+	if batch.data != nil {
+		return batch.commitAtomic()
+	}
+	err := batch.end()
+	_ = batch.pipe.Close()
+	if copyErr := <-batch.copied; copyErr != nil {
+		err = copyErr
+	}
+	if err != nil {
+		_ = batch.tx.Rollback()
+		return 0, err
+	}
+	if batch.saver.Mutex {
+		batch.saver.insertMutex.Lock()
+		defer batch.saver.insertMutex.Unlock()
+	}
+	inserted, err := batch.merge()
+	if err != nil {
+		_ = batch.tx.Rollback()
+		return 0, err
+	}
+	if err := batch.tx.Commit(); err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+// commitAtomic copies the rows kept in memory and merges them on the transaction of the saver, without committing it.
+// The staging table is dropped after the merge, so it doesn't live until the end of the transaction.
+func (batch *copyBatch) commitAtomic() (int, error) {
+	if err := batch.end(); err != nil {
+		return 0, err
+	}
+	batch.saver.txMutex.Lock()
+	defer batch.saver.txMutex.Unlock()
+	if err := batch.createStaging(); err != nil {
+		return 0, err
+	}
+	if _, err := batch.tx.CopyFrom(batch.data, batch.copyStatement()); err != nil {
+		return 0, err
+	}
+	inserted, err := batch.merge()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := batch.tx.Exec("DROP TABLE " + batch.staging); err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+// merge inserts the rows of the staging table into the target table.
+func (batch *copyBatch) merge() (int, error) {
+	stmt, err := batch.mergeStatement()
+	if err != nil {
+		return 0, err
+	}
+	result, err := batch.tx.Exec(stmt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// mergeStatement returns the statement which inserts the staging rows into the target table, using the same
+// conflict handling as the ORM inserts. NULL values are replaced by the column defaults, like ORM inserts use DEFAULT for them.
+func (batch *copyBatch) mergeStatement() (string, error) {
+	columns, err := batch.saver.getColumns(batch.tx, batch.table)
+	if err != nil {
+		return "", err
+	}
+	values := make([]string, len(batch.table.Fields))
+	for i, field := range batch.table.Fields {
+		value := "s." + string(field.Column)
+		if batch.fields != nil && batch.fields[i].encoder == nil {
+			value = fmt.Sprintf("%s::%s", value, batch.fields[i].cast)
+		}
+		if column, ok := columns[field.SQLName]; ok && column.ColumnDefault != "" {
+			value = fmt.Sprintf("COALESCE(%s, %s)", value, column.ColumnDefault)
+		} else if field.Default != "" {
+			value = fmt.Sprintf("COALESCE(%s, %s)", value, field.Default)
+		}
+		values[i] = value
+	}
+	var stmt strings.Builder
+	fmt.Fprintf(&stmt, "INSERT INTO %s AS %s (%s) SELECT %s FROM %s AS s",
+		batch.table.SQLName, batch.table.Alias, batch.columns(), strings.Join(values, ", "), batch.staging)
+	if len(batch.config.OnConflict) > 0 {
+		stmt.WriteString(" ON CONFLICT ")
+		stmt.WriteString(batch.config.OnConflict)
+		if strings.HasSuffix(strings.ToUpper(strings.TrimSpace(batch.config.OnConflict)), "DO UPDATE") {
+			sets := batch.config.Set
+			if len(sets) == 0 {
+				sets = make([]string, len(batch.table.DataFields))
+				for i, field := range batch.table.DataFields {
+					sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", field.Column, field.Column)
+				}
+			}
+			stmt.WriteString(" SET ")
+			stmt.WriteString(strings.Join(sets, ", "))
+		}
+	}
+	return stmt.String(), nil
+}
+
+// tableColumn is a column of a table used on copy mode.
+type tableColumn struct {
+	Name          string // Name of the column
+	Type          string // Type of the column, with its modifiers
+	TypeName      string // Name of the base type of the column
+	ColumnDefault string // Default expression of the column, if any
+}
+
+// getColumns returns the types and default expressions of the columns of a table, by column name.
+// They are read once per table and saver.
+func (saver *PostgresSaver) getColumns(tx *pg.Tx, table *orm.Table) (map[string]*tableColumn, error) {
+	saver.chanMutex.Lock()
+	columns, ok := saver.columns[string(table.SQLName)]
+	saver.chanMutex.Unlock()
+	if ok {
+		return columns, nil
+	}
+	var list []*tableColumn
+	if _, err := tx.Query(&list, `SELECT a.attname AS name, format_type(a.atttypid, a.atttypmod) AS type,
+		t.typname AS type_name, pg_get_expr(d.adbin, d.adrelid) AS column_default
+		FROM pg_attribute a JOIN pg_type t ON t.oid = a.atttypid
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = ?::regclass AND a.attnum > 0 AND NOT a.attisdropped`, string(table.SQLName)); err != nil {
+		return nil, err
+	}
+	columns = make(map[string]*tableColumn, len(list))
+	for _, column := range list {
+		columns[column.Name] = column
+	}
+	saver.chanMutex.Lock()
+	saver.columns[string(table.SQLName)] = columns
+	saver.chanMutex.Unlock()
+	return columns, nil
+}
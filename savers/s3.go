@@ -0,0 +1,309 @@
+package savers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/remote"
+	"github.com/clcert/osr/utils"
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultS3PartSize is the size in MB of the parts of the multipart uploads, if it's not defined in the config.
+const DefaultS3PartSize = 16
+
+// S3Config defines a configuration for a S3 saver.
+type S3Config struct {
+	ServerName string                     // Name of the storage as declared in the s3 section of OSR config
+	Bucket     string                     // Bucket where the files are saved. If it doesn't exist, it's created
+	Path       string                     // Prefix of the keys of the saved files
+	PartSize   uint64                     // Size in MB of the parts uploaded. Only one part per file is kept in memory. Min 5, default 16
+	FileConfig map[string]*SFTPFileConfig // A map with the outID of the savables as the key, and a configuration as a value. Files cannot be appended
+}
+
+// S3Saver defines a saver which saves the objects in CSV files, on a bucket of a S3 compatible storage.
+// Each file is uploaded while it's written, using a multipart upload.
+type S3Saver struct {
+	*S3Config                    // S3Config related to the saver
+	name      string             // Name for the saver instance
+	server    *remote.S3Server   // Storage configuration
+	client    *minio.Client      // Storage client
+	outFiles  map[string]*S3File // A map with file writers, where the key is the outID.
+	finished  chan bool          // True if channel finished parsing files
+	objects   chan Savable       // List of objects to save
+	inserted  int                // number of inserted data rows
+	stats     outIDCounter       // Objects saved and errored by outID
+	errors    []error            // List of errors
+	log       *logs.OSRLog       // Saver log
+	ctx       context.Context    // Context of the process using the saver
+}
+
+// S3File defines a specific file where to save the objects.
+type S3File struct {
+	key    string         // Key of the object
+	pipe   *io.PipeWriter // Writer connected to the upload
+	writer *csv.Writer    // CSV writer over the pipe
+	wg     sync.WaitGroup // Waits for the upload to end
+	err    error          // Error returned by the upload
+	rows   int            // Number of objects written
+}
+
+// New uses the configuration defined in a S3Config to create a new instance.
+func (config *S3Config) New(name string, params utils.Params) (*S3Saver, error) {
+	err := config.Format(params)
+	if err != nil {
+		return nil, err
+	}
+	log, err := logs.NewLog(name)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Saver{
+		S3Config: config,
+		name:     name,
+		finished: make(chan bool, 1),
+		objects:  make(chan Savable),
+		errors:   make([]error, 0),
+		outFiles: make(map[string]*S3File),
+		log:      log,
+	}, nil
+}
+
+func (config *S3Config) Format(params utils.Params) error {
+	if params == nil {
+		return nil
+	}
+	config.ServerName = params.FormatString(config.ServerName)
+	config.Bucket = params.FormatString(config.Bucket)
+	config.Path = params.FormatString(config.Path)
+	newFileConfig := make(map[string]*SFTPFileConfig)
+	for k, v := range config.FileConfig {
+		newFileConfig[params.FormatString(k)] = v.Format(params)
+	}
+	config.FileConfig = newFileConfig
+	return nil
+}
+
+func (saver *S3Saver) Start(ctx context.Context) error {
+	saver.ctx = ctx
+	if saver.ServerName == "" || saver.Bucket == "" {
+		return fmt.Errorf("mandatory config fields not initialized")
+	}
+	if saver.PartSize == 0 {
+		saver.PartSize = DefaultS3PartSize
+	}
+	if saver.FileConfig == nil {
+		saver.FileConfig = make(map[string]*SFTPFileConfig)
+	}
+
+	// Connect to storage
+	server, err := remote.GetS3Server(saver.ServerName)
+	if err != nil {
+		return err
+	}
+	client, err := server.NewClient()
+	if err != nil {
+		return err
+	}
+	saver.server = server
+	saver.client = client
+
+	// Create bucket if it doesn't exist
+	exists, err := client.BucketExists(context.Background(), saver.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = client.MakeBucket(context.Background(), saver.Bucket, minio.MakeBucketOptions{
+			Region: server.Region,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create outFiles and prepare serializers
+	for outName, outConfig := range saver.FileConfig {
+		if err := saver.createOutFile(outName, outConfig); err != nil {
+			saver.abortFiles(err)
+			return err
+		}
+	}
+	go func() {
+		for newObject := range saver.objects {
+			saver.writeToFile(newObject)
+		}
+		saver.finished <- true
+	}()
+	return nil
+}
+
+// There are no messages (yet) for this saver
+func (saver *S3Saver) SendMessage(msg interface{}) error {
+	return nil
+}
+
+func (saver *S3Saver) Save(objs ...interface{}) error {
+	if saver.ctx != nil && saver.ctx.Err() != nil {
+		return saver.ctx.Err()
+	}
+	for _, obj := range objs {
+		switch obj.(type) {
+		case Savable:
+			saver.objects <- obj.(Savable)
+		default:
+			saver.objects <- Savable{Object: obj}
+		}
+	}
+	return nil
+}
+
+// Finish closes all the files and waits until their uploads end.
+func (saver *S3Saver) Finish() error {
+	close(saver.objects)
+	<-saver.finished
+	return saver.closeFiles()
+}
+
+// closeFiles flushes and closes the files, returning the first upload error.
+func (saver *S3Saver) closeFiles() (err error) {
+	for outID, outFile := range saver.outFiles {
+		outFile.writer.Flush()
+		outFile.pipe.Close()
+		outFile.wg.Wait()
+		if outFile.err != nil {
+			saver.errors = append(saver.errors, outFile.err)
+			saver.stats.discard(outID, outFile.rows)
+			if err == nil {
+				err = outFile.err
+			}
+		}
+	}
+	return
+}
+
+// abortFiles cancels the uploads of the files, so no partial files are saved.
+func (saver *S3Saver) abortFiles(err error) {
+	for _, outFile := range saver.outFiles {
+		outFile.pipe.CloseWithError(err)
+		outFile.wg.Wait()
+	}
+}
+
+func (saver *S3Saver) GetErrors() []error {
+	return saver.errors
+}
+
+func (saver *S3Saver) GetInserted() int {
+	return saver.inserted
+}
+
+func (saver *S3Saver) GetStats() models.SaverStats {
+	return saver.stats.copy()
+}
+
+func (saver *S3Saver) GetAttachments() []string {
+	return []string{saver.log.Path}
+}
+
+func (saver *S3Saver) GetName() string {
+	return saver.name
+}
+
+func (saver *S3Saver) writeToFile(savable Savable) {
+	outID := savable.GetOutID()
+	file, ok := saver.outFiles[outID]
+	if !ok {
+		structName := savable.StructName()
+		file, ok = saver.outFiles[structName]
+		if ok {
+			outID = structName
+		} else {
+			saver.FileConfig[outID] = &SFTPFileConfig{
+				FileName: strings.Replace(outID, "/", "-", -1),
+				Fields:   savable.FieldNames(), // all the fields
+			}
+			if err := saver.createOutFile(outID, saver.FileConfig[outID]); err != nil {
+				saver.errors = append(saver.errors, fmt.Errorf("object could not be saved. The file didn't exist and it was impossible to create it: %s", err))
+				saver.stats.addErrors(outID, 1)
+				return
+			}
+			// It should exist now
+			file = saver.outFiles[outID]
+		}
+	}
+	allFields := savable.Fields()
+	outConfig, ok := saver.FileConfig[outID]
+	if !ok {
+		saver.errors = append(saver.errors, fmt.Errorf("there is no config for this file type"))
+		saver.stats.addErrors(outID, 1)
+		return
+	}
+	values := make([]string, len(outConfig.Fields))
+	for i, field := range outConfig.Fields {
+		fieldValue, ok := allFields[field]
+		if ok {
+			values[i] = fmt.Sprintf("%v", fieldValue)
+		}
+	}
+	if err := file.writer.Write(values); err != nil {
+		saver.errors = append(saver.errors, err)
+		saver.stats.addErrors(outID, 1)
+		return
+	}
+	saver.inserted++
+	file.rows++
+	saver.stats.addInserted(outID, 1)
+}
+
+// createOutFile starts the upload of a file, writing its header.
+// The upload reads the file from a pipe, so its content is not kept in memory.
+func (saver *S3Saver) createOutFile(name string, config *SFTPFileConfig) error {
+	if saver.client == nil {
+		return fmt.Errorf("s3 client not initialized")
+	}
+	if len(config.Fields) == 0 {
+		return fmt.Errorf("must declare fields to write on file")
+	}
+	if config.Append {
+		return fmt.Errorf("s3 files cannot be appended")
+	}
+	reader, writer := io.Pipe()
+	outFile := &S3File{
+		key:    path.Join(saver.Path, config.FileName),
+		pipe:   writer,
+		writer: csv.NewWriter(writer),
+	}
+	outFile.wg.Add(1)
+	go func() {
+		defer outFile.wg.Done()
+		// The upload is not cancelled with the context, because Finish must save the received objects
+		info, err := saver.client.PutObject(context.Background(), saver.Bucket, outFile.key, reader, -1, minio.PutObjectOptions{
+			ContentType: "text/csv",
+			PartSize:    saver.PartSize * 1024 * 1024,
+		})
+		if err != nil {
+			outFile.err = fmt.Errorf("cannot upload %s: %s", outFile.key, err)
+			reader.CloseWithError(outFile.err)
+			return
+		}
+		saver.log.WithFields(logrus.Fields{
+			"bucket": saver.Bucket,
+			"key":    outFile.key,
+			"size":   info.Size,
+		}).Info("File uploaded")
+	}()
+	saver.outFiles[name] = outFile
+	if err := outFile.writer.Write(config.Fields); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,20 +1,28 @@
 // Savers are the media where the scanned information is stored.
 //
-// Currently there are two different savers: SFTP and postgres
+// Currently there are five different savers: SFTP, S3, local, postgres and ClickHouse
 //
 // SFTP saves the information into files in a remote server, in CSV files
 //
+// S3 saves the information into CSV files in a bucket of a S3 compatible storage (like MinIO)
+//
+// Local saves the information into CSV, JSON Lines or Parquet files in a local folder
+//
 // Postgres saves the information in the OSR configured Postgresql Database.
+//
+// ClickHouse saves the information in the OSR configured ClickHouse Database, in batches.
 
 package savers
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
 
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/mailer"
+	"github.com/clcert/osr/models"
 	"github.com/clcert/osr/panics"
 	"github.com/clcert/osr/utils"
 	"github.com/fatih/structs"
@@ -27,7 +35,8 @@ type Saver interface {
 	// should have different Names.
 	GetName() string
 	// Start starts the saver. Returns error if the server could not be started.
-	Start() error
+	// When ctx is done, Save stops accepting objects, but Finish still saves the received ones.
+	Start(ctx context.Context) error
 	// Finish sends a message to the saver to finish all its routines. When it returns, you can be sure that
 	// all the files were saved
 	Finish() error
@@ -35,16 +44,36 @@ type Saver interface {
 	SendMessage(msg interface{}) error
 	// GetErrors returns a list of errors the saver has produced.
 	GetErrors() []error
+	// GetInserted returns the number of objects the saver has stored.
+	GetInserted() int
+	// GetStats returns the number of objects the saver has stored and couldn't store, by outID.
+	GetStats() models.SaverStats
 	// Save saves an object and returns an error if the server returns an error.
 	Save(objs ...interface{}) error
 }
 
+// AtomicSaver is implemented by the savers which can store all the objects of a process atomically.
+// If IsAtomic returns true, the objects are only stored when Commit is called after Finish, and Rollback discards them.
+type AtomicSaver interface {
+	Saver
+	// IsAtomic returns true if the saver was configured to store the objects atomically.
+	IsAtomic() bool
+	// Commit stores the objects received by the saver. It must be called after Finish.
+	Commit() error
+	// Rollback discards the objects received by the saver. It must be called after Finish.
+	Rollback() error
+}
+
 // Config defines a saver in a process. It must define only one from [SFTP, HTTP, Script, ...]
 // If you want to extend the savers, you must add a new type of config for the new saver.
 type Config struct {
-	Type     string          // type of the config (sftp, postgres)
-	SFTP     *SFTPConfig     // Config if type is sftp
-	Postgres *PostgresConfig // Config if type is postgres
+	Ref        string            // Name of a saver defined in the savers section of the task file. If set, the other fields are ignored.
+	Type       string            // type of the config (sftp, postgres, s3, clickhouse, local)
+	SFTP       *SFTPConfig       // Config if type is sftp
+	Postgres   *PostgresConfig   // Config if type is postgres
+	S3         *S3Config         // Config if type is s3
+	Clickhouse *ClickhouseConfig // Config if type is clickhouse
+	Local      *LocalConfig      // Config if type is local
 }
 
 // A savable object is an object sent to being saved. It allows to add metainformation to the savable object, via a hashmap.
@@ -61,6 +90,12 @@ func (config Config) New(name string, params utils.Params) (Saver, error) {
 		return config.SFTP.New(name, params)
 	case config.Postgres != nil:
 		return config.Postgres.New(name, params)
+	case config.S3 != nil:
+		return config.S3.New(name, params)
+	case config.Clickhouse != nil:
+		return config.Clickhouse.New(name, params)
+	case config.Local != nil:
+		return config.Local.New(name, params)
 	default:
 		return nil, fmt.Errorf("invalid saver")
 	}
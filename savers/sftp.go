@@ -1,6 +1,7 @@
 package savers
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"sync"
 
 	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
 	"github.com/clcert/osr/remote"
 	"github.com/clcert/osr/utils"
 	"github.com/pkg/sftp"
@@ -44,8 +46,10 @@ type SFTPSaver struct {
 	finished    chan bool            // True if channel finished parsing files
 	objects     chan Savable         // List of objects to save
 	inserted    int                  // number of inserted data rows
+	stats       outIDCounter         // Objects saved and errored by outID
 	errors      []error              // List of errors
 	log         *logs.OSRLog         // Saver log
+	ctx         context.Context      // Context of the process using the saver
 }
 
 // SFTPFile defines a specific file where to save the objects.
@@ -90,7 +94,8 @@ func (config *SFTPConfig) Format(params utils.Params) error {
 	return nil
 }
 
-func (saver *SFTPSaver) Start() error {
+func (saver *SFTPSaver) Start(ctx context.Context) error {
+	saver.ctx = ctx
 
 	// Connect to server
 	server, err := remote.GetServer(saver.ServerName)
@@ -131,6 +136,9 @@ func (saver *SFTPSaver) SendMessage(msg interface{}) error {
 }
 
 func (saver *SFTPSaver) Save(objs ...interface{}) error {
+	if saver.ctx != nil && saver.ctx.Err() != nil {
+		return saver.ctx.Err()
+	}
 	for _, obj := range objs {
 		switch obj.(type) {
 		case Savable:
@@ -157,6 +165,14 @@ func (saver *SFTPSaver) GetErrors() []error {
 	return saver.errors
 }
 
+func (saver *SFTPSaver) GetInserted() int {
+	return saver.inserted
+}
+
+func (saver *SFTPSaver) GetStats() models.SaverStats {
+	return saver.stats.copy()
+}
+
 func (saver *SFTPSaver) GetAttachments() []string {
 	return []string{saver.log.Path}
 }
@@ -171,8 +187,9 @@ func (saver *SFTPSaver) writeToFile(savable Savable) {
 	if !ok {
 		structName := savable.StructName()
 		file, ok = saver.outFiles[structName]
-		if !ok {
-
+		if ok {
+			outID = structName
+		} else {
 			saver.FileConfig[outID] = &SFTPFileConfig{
 				FileName: strings.Replace(outID, "/", "-", -1),
 				Append:   saver.SFTPConfig.Append,
@@ -180,7 +197,8 @@ func (saver *SFTPSaver) writeToFile(savable Savable) {
 			}
 
 			if err := saver.createOutFile(outID, saver.FileConfig[outID]); err != nil {
-				fmt.Errorf("object could not be saved. The file didn't exist and it was impossible to create it")
+				saver.errors = append(saver.errors, fmt.Errorf("object could not be saved. The file didn't exist and it was impossible to create it: %s", err))
+				saver.stats.addErrors(outID, 1)
 				return
 			}
 
@@ -192,6 +210,8 @@ func (saver *SFTPSaver) writeToFile(savable Savable) {
 	outConfig, ok := saver.FileConfig[outID]
 	if !ok {
 		saver.errors = append(saver.errors, fmt.Errorf("there is no config for this file type"))
+		saver.stats.addErrors(outID, 1)
+		return
 	}
 	values := make([]string, len(outConfig.Fields))
 	for i, field := range outConfig.Fields {
@@ -206,7 +226,11 @@ func (saver *SFTPSaver) writeToFile(savable Savable) {
 	}
 	if err != nil {
 		saver.errors = append(saver.errors, err)
+		saver.stats.addErrors(outID, 1)
+		return
 	}
+	saver.inserted++
+	saver.stats.addInserted(outID, 1)
 }
 
 func (saver *SFTPSaver) createOutFile(name string, config *SFTPFileConfig) error {
@@ -0,0 +1,63 @@
+package savers
+
+import (
+	"sync"
+
+	"github.com/clcert/osr/models"
+)
+
+// outIDCounter counts the objects saved and errored by a saver, by outID.
+// It can be used concurrently, and its zero value is ready to use.
+type outIDCounter struct {
+	mutex sync.Mutex        // Protects the stats
+	stats models.SaverStats // Stats by outID
+}
+
+// get returns the stats of an outID, creating them if they don't exist. The mutex must be locked.
+func (counter *outIDCounter) get(outID string) *models.OutIDStats {
+	if counter.stats == nil {
+		counter.stats = make(models.SaverStats)
+	}
+	stats, ok := counter.stats[outID]
+	if !ok {
+		stats = &models.OutIDStats{}
+		counter.stats[outID] = stats
+	}
+	return stats
+}
+
+// addInserted adds n objects saved with an outID.
+func (counter *outIDCounter) addInserted(outID string, n int) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.get(outID).Inserted += n
+}
+
+// addErrors adds n objects with an outID which couldn't be saved.
+func (counter *outIDCounter) addErrors(outID string, n int) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	counter.get(outID).Errors += n
+}
+
+// discard marks as errored n objects with an outID which were counted as saved, but were lost
+// afterwards (e.g. because the upload of their file failed).
+func (counter *outIDCounter) discard(outID string, n int) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	stats := counter.get(outID)
+	stats.Inserted -= n
+	stats.Errors += n
+}
+
+// copy returns a copy of the stats, which can be read while the saver is used.
+func (counter *outIDCounter) copy() models.SaverStats {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+	stats := make(models.SaverStats, len(counter.stats))
+	for outID, outIDStats := range counter.stats {
+		statsCopy := *outIDStats
+		stats[outID] = &statsCopy
+	}
+	return stats
+}
@@ -0,0 +1,53 @@
+// Package scheduler triggers the execution of task files at the times defined
+// by cron expressions in the "scheduler" section of the OSR config file.
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+)
+
+// Entry defines a group of task files executed at the times defined by a cron expression.
+type Entry struct {
+	Name     string        // Name of the entry. It must be unique.
+	Cron     string        // Cron expression (minute, hour, day of month, month, day of week) or descriptor (@daily, @every 1h, ...)
+	Tasks    []string      // Task files executed, relative to the tasks folder. They are executed sequentially.
+	Params   []string      // Params passed to the tasks, in key:value format. They have the same preference as command line params.
+	schedule cron.Schedule // Parsed cron expression
+}
+
+// GetEntries returns the entries defined in the scheduler section of the config file.
+// It returns an error if an entry is not valid.
+func GetEntries() ([]*Entry, error) {
+	var entries []*Entry
+	if err := viper.UnmarshalKey("scheduler", &entries); err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{})
+	for i, entry := range entries {
+		if len(entry.Name) == 0 {
+			return nil, fmt.Errorf("scheduler entry with index %d has no name", i)
+		}
+		if _, ok := names[entry.Name]; ok {
+			return nil, fmt.Errorf("scheduler entry %s is defined more than once", entry.Name)
+		}
+		names[entry.Name] = struct{}{}
+		if len(entry.Tasks) == 0 {
+			return nil, fmt.Errorf("scheduler entry %s has no task files", entry.Name)
+		}
+		schedule, err := cron.ParseStandard(entry.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("scheduler entry %s has an invalid cron expression: %s", entry.Name, err)
+		}
+		entry.schedule = schedule
+	}
+	return entries, nil
+}
+
+// Next returns the next time the entry is triggered after the given time.
+func (entry *Entry) Next(t time.Time) time.Time {
+	return entry.schedule.Next(t)
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/clcert/osr/databases"
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/tasks"
+	"github.com/go-pg/pg/v10"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Scheduler executes the task files of its entries at the times defined by their cron expressions.
+// A task file is never executed twice at the same time: if it is triggered while it's still running,
+// the new execution is skipped.
+type Scheduler struct {
+	Entries []*Entry        // Entries defined in config file
+	cron    *cron.Cron      // Cron runner
+	db      *pg.DB          // DB writer used to save the last executions
+	mutex   sync.Mutex      // Protects running map
+	running map[string]bool // Task files being executed right now
+	ctx     context.Context // Context of the scheduler. Running tasks are cancelled when it's done.
+}
+
+// Execution represents a future execution of a scheduler entry.
+type Execution struct {
+	Entry *Entry    // Entry triggered
+	Time  time.Time // Time of the execution
+}
+
+// New returns a new scheduler with the entries defined in the config file.
+func New() (*Scheduler, error) {
+	entries, err := GetEntries()
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		Entries: entries,
+		running: make(map[string]bool),
+	}, nil
+}
+
+// Run starts the scheduler and blocks until the context is done.
+// Then, it cancels the task files being executed and waits for them to finish.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if len(s.Entries) == 0 {
+		return fmt.Errorf("there are no entries defined in scheduler config")
+	}
+	db, err := databases.GetPostgresWriter()
+	if err != nil {
+		return err
+	}
+	s.db = db
+	defer s.db.Close()
+	s.ctx = ctx
+	s.cron = cron.New(cron.WithChain(cron.Recover(cron.PrintfLogger(logs.Log))))
+	for _, entry := range s.Entries {
+		entry := entry
+		s.cron.Schedule(entry.schedule, cron.FuncJob(func() {
+			s.trigger(entry)
+		}))
+		logs.Log.WithFields(logrus.Fields{
+			"entry": entry.Name,
+			"cron":  entry.Cron,
+			"tasks": entry.Tasks,
+			"next":  entry.Next(time.Now()),
+		}).Info("Entry scheduled")
+	}
+	s.cron.Start()
+	logs.Log.Info("Scheduler started")
+	<-ctx.Done()
+	logs.Log.Info("Stopping scheduler, waiting for running tasks...")
+	<-s.cron.Stop().Done()
+	logs.Log.Info("Scheduler stopped")
+	return nil
+}
+
+// Upcoming returns the next executions of all the entries after the given time, sorted by time.
+func (s *Scheduler) Upcoming(from time.Time, count int) []*Execution {
+	executions := make([]*Execution, 0)
+	for _, entry := range s.Entries {
+		next := from
+		for i := 0; i < count; i++ {
+			next = entry.Next(next)
+			if next.IsZero() {
+				break
+			}
+			executions = append(executions, &Execution{
+				Entry: entry,
+				Time:  next,
+			})
+		}
+	}
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].Time.Before(executions[j].Time)
+	})
+	if len(executions) > count {
+		executions = executions[:count]
+	}
+	return executions
+}
+
+// trigger executes sequentially the task files of an entry.
+func (s *Scheduler) trigger(entry *Entry) {
+	logs.Log.WithFields(logrus.Fields{
+		"entry": entry.Name,
+	}).Info("Entry triggered")
+	for _, taskFile := range entry.Tasks {
+		if s.ctx.Err() != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"entry": entry.Name,
+				"task":  taskFile,
+			}).Warn("Scheduler stopped, skipping task...")
+			continue
+		}
+		if !s.lock(taskFile) {
+			logs.Log.WithFields(logrus.Fields{
+				"entry": entry.Name,
+				"task":  taskFile,
+			}).Warn("Task is still running since a previous execution, skipping it...")
+			continue
+		}
+		// The task is unlocked even if it panics, so the next executions are not skipped
+		func() {
+			defer s.unlock(taskFile)
+			s.execute(entry, taskFile)
+		}()
+	}
+}
+
+// execute executes a task file and saves the result of the execution.
+func (s *Scheduler) execute(entry *Entry, taskFile string) {
+	scheduled := &models.ScheduledTask{
+		Entry:      entry.Name,
+		TaskFile:   taskFile,
+		LastRun:    time.Now(),
+		LastStatus: models.FAIL,
+	}
+	defer func() {
+		if err := scheduled.Save(s.db); err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"entry": entry.Name,
+				"task":  taskFile,
+			}).Errorf("Couldn't save scheduled task execution: %s", err)
+		}
+	}()
+	config, err := tasks.ParseConfig(taskFile)
+	if err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"entry": entry.Name,
+			"task":  taskFile,
+		}).Errorf("File parsing error: %s", err)
+		return
+	}
+	task, err := tasks.New(config, entry.Params)
+	if err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"entry": entry.Name,
+			"task":  taskFile,
+		}).Errorf("Couldn't create task: %s", err)
+		return
+	}
+	defer task.Close()
+	scheduled.LastTaskID = task.TaskSession.ID
+	scheduled.LastStatus = models.PROCESSING
+	if err := scheduled.Save(s.db); err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"entry": entry.Name,
+			"task":  taskFile,
+		}).Errorf("Couldn't save scheduled task execution: %s", err)
+	}
+	task.ExecuteContext(s.ctx)
+	scheduled.LastStatus = task.TaskSession.Status
+}
+
+// lock marks a task file as running. It returns false if it was already running.
+func (s *Scheduler) lock(taskFile string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.running[taskFile] {
+		return false
+	}
+	s.running[taskFile] = true
+	return true
+}
+
+// unlock marks a task file as not running.
+func (s *Scheduler) unlock(taskFile string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.running, taskFile)
+}
@@ -0,0 +1,310 @@
+package sources
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/openpgp"
+)
+
+// ChecksumConfig defines how to verify the integrity of the entries of a source.
+// The checksum and signature files are entries of the same source, so they must match its filter.
+// They are not returned as entries. Entries are returned after their checksum and signature files are found,
+// and the entries without them are skipped when the source ends.
+type ChecksumConfig struct {
+	Sidecar   string // Name of the file with the checksum of each entry, where "*" is replaced by the entry name (e.g. "*.sha256"). It can also be a file with the checksums of several entries, like SHA256SUMS
+	Algorithm string // Hash algorithm of the checksums: sha256, sha1 or md5. By default, it's inferred from the sidecar name, or sha256
+	Signature string // Name of the detached GPG signature of each entry, where "*" is replaced by the entry name (e.g. "*.sig" or "*.asc")
+	Keyring   string // Path of the keyring with the public keys trusted to sign the entries. If it's relative, it's relative to OSR home folder
+}
+
+// ChecksumSource wraps a source, returning only the entries whose checksums and signatures are valid.
+// The entries are copied to temporary files while they are verified.
+type ChecksumSource struct {
+	Source                             // Wrapped source
+	*ChecksumConfig                    // Configuration
+	keyring         openpgp.EntityList // Keys trusted to sign the entries
+	files           map[string][]byte  // Content of the checksum and signature files found, by path
+	pending         []Entry            // Entries waiting for their checksum or signature files
+	ready           []Entry            // Verified entries not returned yet
+	log             *logs.OSRLog       // Log of the wrapped source
+}
+
+// New returns a source which verifies the entries of another one.
+func (config *ChecksumConfig) New(source Source, params utils.Params) (*ChecksumSource, error) {
+	config = config.Format(params)
+	if config.Sidecar == "" && config.Signature == "" {
+		return nil, fmt.Errorf("invalid checksum config: you need to define a sidecar or a signature")
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = "sha256"
+		for _, algorithm := range []string{"md5", "sha1"} {
+			if strings.Contains(strings.ToLower(config.Sidecar), algorithm) {
+				config.Algorithm = algorithm
+			}
+		}
+	}
+	if newHash(config.Algorithm) == nil {
+		return nil, fmt.Errorf("invalid checksum config: unknown algorithm %s", config.Algorithm)
+	}
+	checksumSource := &ChecksumSource{
+		Source:         source,
+		ChecksumConfig: config,
+		files:          make(map[string][]byte),
+		pending:        make([]Entry, 0),
+		ready:          make([]Entry, 0),
+		log:            sourceLog(source),
+	}
+	if config.Signature != "" {
+		keyring, err := readKeyring(config.Keyring)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum config: cannot read keyring: %s", err)
+		}
+		checksumSource.keyring = keyring
+	}
+	return checksumSource, nil
+}
+
+// Format formats the configuration, using the params defined in the task
+func (config *ChecksumConfig) Format(params utils.Params) *ChecksumConfig {
+	return &ChecksumConfig{
+		Sidecar:   params.FormatString(config.Sidecar),
+		Algorithm: params.FormatString(config.Algorithm),
+		Signature: params.FormatString(config.Signature),
+		Keyring:   params.FormatString(config.Keyring),
+	}
+}
+
+// newHash returns a new hash for an algorithm name, or nil if it is unknown.
+func newHash(algorithm string) hash.Hash {
+	switch strings.ToLower(algorithm) {
+	case "sha256":
+		return sha256.New()
+	case "sha1":
+		return sha1.New()
+	case "md5":
+		return md5.New()
+	default:
+		return nil
+	}
+}
+
+// readKeyring reads an armored or binary keyring.
+func readKeyring(path string) (openpgp.EntityList, error) {
+	if path == "" {
+		return nil, fmt.Errorf("keyring not defined")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(viper.GetString("folders.home"), path)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isArmored(content) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(content))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(content))
+}
+
+// isArmored returns true if the content of a key or signature is ASCII armored.
+func isArmored(content []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(content), []byte("-----BEGIN"))
+}
+
+// Next returns the next verified entry.
+func (source *ChecksumSource) Next() Entry {
+	for {
+		if len(source.ready) > 0 {
+			entry := source.ready[0]
+			source.ready = source.ready[1:]
+			return entry
+		}
+		entry := source.Source.Next()
+		if entry == nil {
+			for _, pending := range source.pending {
+				source.log.WithFields(logrus.Fields{
+					"path": pending.Path(),
+				}).Error("Checksum or signature file not found, skipping entry")
+			}
+			source.pending = source.pending[:0]
+			return nil
+		}
+		if source.isVerificationFile(entry) {
+			source.addVerificationFile(entry)
+			continue
+		}
+		source.pending = append(source.pending, entry)
+		source.verifyPending()
+	}
+}
+
+// Commit commits the wrapped source.
+func (source *ChecksumSource) Commit() error {
+	return Commit(source.Source)
+}
+
+// isVerificationFile returns true if the entry is a checksum or signature file.
+func (source *ChecksumSource) isVerificationFile(entry Entry) bool {
+	return (source.Sidecar != "" && Matches(entry, source.Sidecar)) ||
+		(source.Signature != "" && Matches(entry, source.Signature))
+}
+
+// addVerificationFile reads and stores the content of a checksum or signature file, and verifies the
+// pending entries which were waiting for it.
+func (source *ChecksumSource) addVerificationFile(entry Entry) {
+	content, err := readAll(entry)
+	if err != nil {
+		source.log.WithFields(logrus.Fields{
+			"path": entry.Path(),
+		}).Errorf("Cannot read checksum or signature file: %s", err)
+		return
+	}
+	source.files[entry.Path()] = content
+	source.verifyPending()
+}
+
+// verifyPending verifies the pending entries with all their checksum and signature files available,
+// adding them to the ready list if they are valid.
+func (source *ChecksumSource) verifyPending() {
+	waiting := source.pending[:0]
+	for _, entry := range source.pending {
+		checksum, hasChecksum := source.files[verificationPath(entry, source.Sidecar)]
+		signature, hasSignature := source.files[verificationPath(entry, source.Signature)]
+		if (source.Sidecar != "" && !hasChecksum) || (source.Signature != "" && !hasSignature) {
+			waiting = append(waiting, entry)
+			continue
+		}
+		verified, err := source.verify(entry, checksum, signature)
+		if err != nil {
+			source.log.WithFields(logrus.Fields{
+				"path": entry.Path(),
+			}).Errorf("Entry failed verification, skipping it: %s", err)
+			continue
+		}
+		source.log.WithFields(logrus.Fields{
+			"path": entry.Path(),
+		}).Info("Entry verified")
+		source.ready = append(source.ready, verified)
+	}
+	source.pending = waiting
+}
+
+// verify copies the entry to a temporary file, checking its checksum and signature.
+func (source *ChecksumSource) verify(entry Entry, checksum, signature []byte) (Entry, error) {
+	writers := make([]io.Writer, 0)
+	var contentHash hash.Hash
+	var expected string
+	if source.Sidecar != "" {
+		var err error
+		expected, err = parseChecksum(checksum, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		contentHash = newHash(source.Algorithm)
+		writers = append(writers, contentHash)
+	}
+	spooled, err := Spool(entry, writers...)
+	if err != nil {
+		_ = entry.Close()
+		return nil, err
+	}
+	if contentHash != nil {
+		actual := hex.EncodeToString(contentHash.Sum(nil))
+		if !strings.EqualFold(actual, expected) {
+			_ = spooled.Close()
+			return nil, fmt.Errorf("%s checksum mismatch: expected %s, got %s", source.Algorithm, expected, actual)
+		}
+	}
+	if source.Signature != "" {
+		if err := source.checkSignature(spooled, signature); err != nil {
+			_ = spooled.Close()
+			return nil, err
+		}
+	}
+	return spooled, nil
+}
+
+// checkSignature checks the detached signature of a spooled entry, and rewinds it.
+func (source *ChecksumSource) checkSignature(entry *SpooledEntry, signature []byte) error {
+	signed, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	if isArmored(signature) {
+		_, err = openpgp.CheckArmoredDetachedSignature(source.keyring, signed, bytes.NewReader(signature))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(source.keyring, signed, bytes.NewReader(signature))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid signature: %s", err)
+	}
+	return entry.rewind()
+}
+
+// verificationPath returns the path of the checksum or signature file of an entry, replacing
+// the "*" of the file name by the name of the entry.
+func verificationPath(entry Entry, name string) string {
+	return strings.TrimSuffix(entry.Path(), entry.Name()) + strings.Replace(name, "*", entry.Name(), 1)
+}
+
+// parseChecksum returns the checksum of a file from the content of a checksum file. The content
+// can be only the checksum, or lines with a checksum and a file name, like the output of sha256sum.
+func parseChecksum(content []byte, name string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 1 && len(lines) == 1 {
+			return fields[0], nil
+		}
+		if len(fields) >= 2 && strings.TrimPrefix(filepath.Base(fields[len(fields)-1]), "*") == name {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("checksum for %s not found in checksum file", name)
+}
+
+// readAll reads and closes an entry.
+func readAll(entry Entry) ([]byte, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer entry.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// sourceLog returns the log of a source created by this package, or the global log if it has none.
+func sourceLog(source Source) *logs.OSRLog {
+	switch s := source.(type) {
+	case *SFTPSource:
+		return s.log
+	case *HTTPSource:
+		return s.log
+	case *ScriptSource:
+		return s.log
+	case *QuerySource:
+		return s.log
+	case *LocalSource:
+		return s.log
+	case *ZipSource:
+		return s.log
+	case *S3Source:
+		return s.log
+	default:
+		return logs.Log
+	}
+}
@@ -0,0 +1,243 @@
+package sources
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/utils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func md5Hex(content []byte) string {
+	sum := md5.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		file     string
+		checksum string
+		err      bool
+	}{
+		{"only checksum", "abc123\n", "scan.csv", "abc123", false},
+		{"sha256sum line", "abc123  scan.csv\n", "scan.csv", "abc123", false},
+		{"binary mode line", "abc123 *scan.csv\n", "scan.csv", "abc123", false},
+		{"several files", "aaa  other.csv\nbbb  scan.csv\nccc  last.csv\n", "scan.csv", "bbb", false},
+		{"file with folder", "bbb  ./data/scan.csv\n", "scan.csv", "bbb", false},
+		{"file not listed", "aaa  other.csv\nccc  last.csv\n", "scan.csv", "", true},
+		{"checksum of other file", "aaa  other.csv\n", "scan.csv", "", true},
+		{"empty file", "", "scan.csv", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checksum, err := parseChecksum([]byte(test.content), test.file)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected an error, got checksum %q", checksum)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if checksum != test.checksum {
+				t.Errorf("expected checksum %q, got %q", test.checksum, checksum)
+			}
+		})
+	}
+}
+
+// newKeyring creates a key, saving its public part as a keyring on a folder.
+func newKeyring(t *testing.T, dir string) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("osr", "test", "osr@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyring bytes.Buffer
+	if err := entity.Serialize(&keyring); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "keyring.gpg")
+	if err := ioutil.WriteFile(path, keyring.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return entity, path
+}
+
+// sign returns the detached binary signature of a content.
+func sign(t *testing.T, entity *openpgp.Entity, content []byte) []byte {
+	var signature bytes.Buffer
+	if err := openpgp.DetachSign(&signature, entity, bytes.NewReader(content), nil); err != nil {
+		t.Fatal(err)
+	}
+	return signature.Bytes()
+}
+
+func TestChecksumSource(t *testing.T) {
+	logs.Log = &logs.OSRLog{Logger: logrus.New()}
+	dir, err := ioutil.TempDir("", "osr-checksum-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entity, keyringPath := newKeyring(t, dir)
+	// Key which is not in the keyring
+	other, err := openpgp.NewEntity("other", "test", "other@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good := []byte("ip,port\n192.0.2.1,443\n")
+	bad := []byte("ip,port\n192.0.2.66,443\n")
+	tests := []struct {
+		name     string
+		config   *ChecksumConfig
+		entries  map[string][]byte
+		expected []string
+	}{
+		{
+			name:   "sidecar files",
+			config: &ChecksumConfig{Sidecar: "*.sha256"},
+			entries: map[string][]byte{
+				"/data/a.csv":        good,
+				"/data/a.csv.sha256": []byte(sha256Hex(good) + "  a.csv\n"),
+				"/data/b.csv":        bad,
+				"/data/b.csv.sha256": []byte(sha256Hex(good) + "  b.csv\n"),
+			},
+			expected: []string{"/data/a.csv"},
+		},
+		{
+			name:   "checksums in uppercase",
+			config: &ChecksumConfig{Sidecar: "*.sha256"},
+			entries: map[string][]byte{
+				"/data/a.csv":        good,
+				"/data/a.csv.sha256": []byte(strings.ToUpper(sha256Hex(good))),
+			},
+			expected: []string{"/data/a.csv"},
+		},
+		{
+			name:   "shared checksum file",
+			config: &ChecksumConfig{Sidecar: "SHA256SUMS"},
+			entries: map[string][]byte{
+				"/data/a.csv":      good,
+				"/data/b.csv":      bad,
+				"/data/c.csv":      good,
+				"/data/SHA256SUMS": []byte(fmt.Sprintf("%s  a.csv\n%s  b.csv\n", sha256Hex(good), sha256Hex(good))),
+			},
+			expected: []string{"/data/a.csv"},
+		},
+		{
+			name:   "algorithm inferred from sidecar name",
+			config: &ChecksumConfig{Sidecar: "*.md5"},
+			entries: map[string][]byte{
+				"/data/a.csv":     good,
+				"/data/a.csv.md5": []byte(md5Hex(good)),
+				"/data/b.csv":     good,
+				"/data/b.csv.md5": []byte(sha256Hex(good)),
+			},
+			expected: []string{"/data/a.csv"},
+		},
+		{
+			name:   "missing sidecar",
+			config: &ChecksumConfig{Sidecar: "*.sha256"},
+			entries: map[string][]byte{
+				"/data/a.csv": good,
+			},
+			expected: []string{},
+		},
+		{
+			name:   "signatures",
+			config: &ChecksumConfig{Signature: "*.sig", Keyring: keyringPath},
+			entries: map[string][]byte{
+				"/data/a.csv":     good,
+				"/data/a.csv.sig": sign(t, entity, good),
+				"/data/b.csv":     bad,
+				"/data/b.csv.sig": sign(t, entity, good),
+				"/data/c.csv":     good,
+				"/data/c.csv.sig": sign(t, other, good),
+			},
+			expected: []string{"/data/a.csv"},
+		},
+		{
+			name:   "checksum and signature",
+			config: &ChecksumConfig{Sidecar: "*.sha256", Signature: "*.sig", Keyring: keyringPath},
+			entries: map[string][]byte{
+				"/data/a.csv":        good,
+				"/data/a.csv.sha256": []byte(sha256Hex(good)),
+				"/data/a.csv.sig":    sign(t, entity, good),
+				"/data/b.csv":        good,
+				"/data/b.csv.sha256": []byte(sha256Hex(bad)),
+				"/data/b.csv.sig":    sign(t, entity, good),
+			},
+			expected: []string{"/data/a.csv"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The entries are returned sorted by path, so verification files can come before or after their entries
+			paths := make([]string, 0, len(test.entries))
+			for path := range test.entries {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			entries := make([]Entry, len(paths))
+			for i, path := range paths {
+				entries[i] = &memoryEntry{path: path, content: test.entries[path]}
+			}
+			source, err := test.config.New(&memorySource{entries: entries}, utils.Params{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			verified := make([]string, 0)
+			for entry := source.Next(); entry != nil; entry = source.Next() {
+				content, err := readAll(entry)
+				if err != nil {
+					t.Fatalf("cannot read %s: %s", entry.Path(), err)
+				}
+				if !bytes.Equal(content, test.entries[entry.Path()]) {
+					t.Errorf("wrong content for %s", entry.Path())
+				}
+				verified = append(verified, entry.Path())
+			}
+			if strings.Join(verified, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected verified entries %v, got %v", test.expected, verified)
+			}
+		})
+	}
+}
+
+func TestChecksumConfigNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *ChecksumConfig
+	}{
+		{"no sidecar or signature", &ChecksumConfig{}},
+		{"unknown algorithm", &ChecksumConfig{Sidecar: "*.sum", Algorithm: "crc32"}},
+		{"signature without keyring", &ChecksumConfig{Signature: "*.sig"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.config.New(&memorySource{}, utils.Params{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
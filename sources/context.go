@@ -0,0 +1,78 @@
+package sources
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// contextReader wraps a reader, returning the context error when the context is done.
+// This allows processes reading an entry to stop when their task is cancelled.
+type contextReader struct {
+	ctx    context.Context // Context to observe
+	reader io.Reader       // Wrapped reader
+}
+
+// newContextReader returns a reader which stops reading when the context is done.
+func newContextReader(ctx context.Context, reader io.Reader) io.Reader {
+	if ctx == nil {
+		return reader
+	}
+	return &contextReader{
+		ctx:    ctx,
+		reader: reader,
+	}
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// nextEntry returns the next entry from a channel, or nil if the channel is closed or
+// the context is done.
+func nextEntry(ctx context.Context, entries <-chan Entry) Entry {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case entry, ok := <-entries:
+		if !ok {
+			return nil
+		}
+		return entry
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// sendEntry sends an entry to a channel. It returns false if the context is done before
+// the entry is received, meaning the source should stop retrieving entries.
+func sendEntry(ctx context.Context, entries chan<- Entry, entry Entry) bool {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case entries <- entry:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleepContext waits for a duration, returning the context error if the context is done before.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,327 @@
+package sources
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/clcert/osr/logs"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
+)
+
+// compression defines a compression format that a decompressed entry can read.
+type compression struct {
+	name       string                             // Name of the format
+	magic      []byte                             // Bytes at the beginning of a compressed file
+	extensions map[string]string                  // Extensions of compressed files, and the extension of the file once decompressed
+	newReader  func(io.Reader) (io.Reader, error) // Returns a reader which decompresses the content of a reader
+}
+
+// compressions are the compression formats detected by decompressed entries.
+var compressions = []*compression{
+	{
+		name:       "gzip",
+		magic:      []byte{0x1f, 0x8b},
+		extensions: map[string]string{".gz": "", ".gzip": "", ".tgz": ".tar"},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:       "bzip2",
+		magic:      []byte("BZh"),
+		extensions: map[string]string{".bz2": "", ".tbz2": ".tar"},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+	},
+	{
+		name:       "xz",
+		magic:      []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		extensions: map[string]string{".xz": "", ".txz": ".tar"},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		},
+	},
+	{
+		name:       "zstd",
+		magic:      []byte{0x28, 0xb5, 0x2f, 0xfd},
+		extensions: map[string]string{".zst": "", ".zstd": ""},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			decoder, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+	{
+		name:       "lz4",
+		magic:      []byte{0x04, 0x22, 0x4d, 0x18},
+		extensions: map[string]string{".lz4": ""},
+		newReader: func(r io.Reader) (io.Reader, error) {
+			return lz4.NewReader(r), nil
+		},
+	},
+}
+
+// maxMagicLength is the number of bytes read to detect the compression of an entry.
+const maxMagicLength = 6
+
+// DecompressedEntry wraps an entry, decompressing its content if it is compressed.
+// The compression is detected by the first bytes of the content or, if they are not
+// recognized, by the extension of the entry. Uncompressed entries are read as they are.
+type DecompressedEntry struct {
+	Entry                   // Wrapped entry
+	reader     io.Reader    // Decompressed reader, available after opening the entry
+	compressed *compression // Compression detected when the entry was opened
+}
+
+// DecompressSource wraps a source, decompressing its entries and expanding the tar
+// entries into the files they contain. The tar is read sequentially, so each file of a tar is
+// copied to a temporary file when it's returned. This way, the files can be read in any order
+// and concurrently, after asking for the next entries of the source.
+type DecompressSource struct {
+	Source                // Wrapped source
+	tarEntry  Entry       // Tar entry being expanded
+	tarReader *tar.Reader // Reader of the tar entry being expanded
+}
+
+// TarChildEntry represents a file inside a tar entry. It can only be read while the
+// tar reader is positioned on the file, so DecompressSource spools it before returning it.
+type TarChildEntry struct {
+	parent Entry       // Tar entry containing the file
+	header *tar.Header // Header of the file in the tar
+	reader *tar.Reader // Reader of the tar, positioned on the file
+}
+
+// NewDecompressedEntry returns an entry which decompresses the content of another one.
+func NewDecompressedEntry(entry Entry) *DecompressedEntry {
+	return &DecompressedEntry{
+		Entry: entry,
+	}
+}
+
+// NewDecompressSource returns a source which decompresses the entries of another one.
+func NewDecompressSource(source Source) *DecompressSource {
+	return &DecompressSource{
+		Source: source,
+	}
+}
+
+// detectCompression returns the compression of a file, based on its first bytes or its name.
+// It returns nil if the file is not compressed.
+func detectCompression(header []byte, name string) *compression {
+	for _, c := range compressions {
+		if bytes.HasPrefix(header, c.magic) {
+			return c
+		}
+	}
+	return compressionByName(name)
+}
+
+// compressionByName returns the compression of a file based on the extension of its name,
+// or nil if the extension is not a known one.
+func compressionByName(name string) *compression {
+	ext := strings.ToLower(path.Ext(name))
+	for _, c := range compressions {
+		if _, ok := c.extensions[ext]; ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// Open opens the wrapped entry and returns a reader with its decompressed content.
+func (entry *DecompressedEntry) Open() (io.Reader, error) {
+	if entry.reader != nil {
+		return entry.reader, nil
+	}
+	reader, err := entry.Entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	buffer := bufio.NewReader(reader)
+	header, err := buffer.Peek(maxMagicLength)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	entry.compressed = detectCompression(header, entry.Entry.Name())
+	if entry.compressed == nil {
+		entry.reader = buffer
+		return entry.reader, nil
+	}
+	decompressed, err := entry.compressed.newReader(buffer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress %s as %s: %s", entry.Path(), entry.compressed.name, err)
+	}
+	entry.reader = decompressed
+	return entry.reader, nil
+}
+
+// Name returns the name of the wrapped entry, without the extension of its compression
+// (e.g. scan.csv.gz is named scan.csv, and scan.tgz is named scan.tar).
+func (entry *DecompressedEntry) Name() string {
+	name := entry.Entry.Name()
+	c := compressionByName(name)
+	if c == nil {
+		return name
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + c.extensions[strings.ToLower(ext)]
+}
+
+// Stat returns the size and modification time of the wrapped entry, if it knows them.
+// The size is the one of the compressed content.
+func (entry *DecompressedEntry) Stat() (int64, time.Time) {
+	if statEntry, ok := entry.Entry.(StatEntry); ok {
+		return statEntry.Stat()
+	}
+	return 0, time.Time{}
+}
+
+// Close closes the decompressed reader and the wrapped entry.
+func (entry *DecompressedEntry) Close() error {
+	if closer, ok := entry.reader.(io.Closer); ok && entry.compressed != nil {
+		if err := closer.Close(); err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"path":        entry.Path(),
+				"compression": entry.compressed.name,
+			}).Errorf("Couldn't close decompressed reader: %s", err)
+		}
+	}
+	entry.reader = nil
+	entry.compressed = nil
+	return entry.Entry.Close()
+}
+
+// Next returns the next entry of the source, decompressed. If the entry is a tar,
+// it returns the files inside it before continuing with the next entry of the wrapped source.
+func (source *DecompressSource) Next() Entry {
+	for {
+		if source.tarReader != nil {
+			if child := source.nextTarChild(); child != nil {
+				return child
+			}
+			continue
+		}
+		next := source.Source.Next()
+		if next == nil {
+			return nil
+		}
+		entry := NewDecompressedEntry(next)
+		if strings.ToLower(path.Ext(entry.Name())) != ".tar" {
+			return entry
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"source": source.GetName(),
+				"path":   entry.Path(),
+			}).Errorf("Cannot open tar entry, skipping it: %s", err)
+			source.closeEntry(entry)
+			continue
+		}
+		source.tarEntry = entry
+		source.tarReader = tar.NewReader(reader)
+	}
+}
+
+// nextTarChild returns the next regular file of the tar being expanded, copied to a temporary file.
+// If there are no more files, it closes the tar entry and returns nil.
+func (source *DecompressSource) nextTarChild() Entry {
+	for {
+		header, err := source.tarReader.Next()
+		if err != nil {
+			if err != io.EOF {
+				logs.Log.WithFields(logrus.Fields{
+					"source": source.GetName(),
+					"path":   source.tarEntry.Path(),
+				}).Errorf("Cannot read tar entry, skipping the rest of it: %s", err)
+			}
+			source.closeEntry(source.tarEntry)
+			source.tarEntry = nil
+			source.tarReader = nil
+			return nil
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		child := &TarChildEntry{
+			parent: source.tarEntry,
+			header: header,
+			reader: source.tarReader,
+		}
+		spooled, err := Spool(child)
+		if err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"source": source.GetName(),
+				"path":   child.Path(),
+			}).Errorf("Cannot copy file of tar entry, skipping it: %s", err)
+			continue
+		}
+		return NewDecompressedEntry(spooled)
+	}
+}
+
+// closeEntry closes an entry of the wrapped source, logging the error if it fails.
+func (source *DecompressSource) closeEntry(entry Entry) {
+	if err := entry.Close(); err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"source": source.GetName(),
+			"path":   entry.Path(),
+		}).Errorf("Couldn't close entry: %s", err)
+	}
+}
+
+// Close closes the tar entry being expanded, if any, and the wrapped source.
+func (source *DecompressSource) Close() error {
+	if source.tarEntry != nil {
+		source.closeEntry(source.tarEntry)
+		source.tarEntry = nil
+		source.tarReader = nil
+	}
+	return source.Source.Close()
+}
+
+// Commit commits the wrapped source.
+func (source *DecompressSource) Commit() error {
+	return Commit(source.Source)
+}
+
+// Open returns a reader with the content of the file. It's only valid until the tar reader moves to the next file.
+func (child *TarChildEntry) Open() (io.Reader, error) {
+	return child.reader, nil
+}
+
+func (child *TarChildEntry) Name() string {
+	return path.Base(child.header.Name)
+}
+
+func (child *TarChildEntry) Dir() string {
+	return path.Dir(child.Path())
+}
+
+// Path returns the path of the file, prefixed with the path of the tar containing it.
+func (child *TarChildEntry) Path() string {
+	return path.Join(child.parent.Path(), child.header.Name)
+}
+
+func (child *TarChildEntry) Stat() (int64, time.Time) {
+	return child.header.Size, child.header.ModTime
+}
+
+func (child *TarChildEntry) Close() error {
+	// The tar entry is closed when all its files were returned
+	return nil
+}
@@ -0,0 +1,155 @@
+package sources
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"sync"
+	"testing"
+)
+
+// memoryEntry is an entry whose content is kept in memory.
+type memoryEntry struct {
+	path    string
+	content []byte
+}
+
+func (entry *memoryEntry) Open() (io.Reader, error) { return bytes.NewReader(entry.content), nil }
+func (entry *memoryEntry) Name() string             { return path.Base(entry.path) }
+func (entry *memoryEntry) Path() string             { return entry.path }
+func (entry *memoryEntry) Dir() string              { return path.Dir(entry.path) }
+func (entry *memoryEntry) Close() error             { return nil }
+
+// memorySource is a source which returns a list of entries.
+type memorySource struct {
+	entries []Entry
+}
+
+func (source *memorySource) GetAttachments() []string       { return nil }
+func (source *memorySource) Init(ctx context.Context) error { return nil }
+func (source *memorySource) GetName() string                { return "memory" }
+func (source *memorySource) GetID() (string, error)         { return "memory", nil }
+func (source *memorySource) Close() error                   { return nil }
+
+func (source *memorySource) Next() Entry {
+	if len(source.entries) == 0 {
+		return nil
+	}
+	entry := source.entries[0]
+	source.entries = source.entries[1:]
+	return entry
+}
+
+// newTarGz returns a gzipped tar with the given files, by name.
+func newTarGz(t *testing.T, files []string, contents map[string][]byte) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range files {
+		content := contents[name]
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// gzipped returns the content compressed with gzip.
+func gzipped(t *testing.T, content []byte) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestDecompressSourceTarChildren(t *testing.T) {
+	files := []string{"scans/a.pcap", "scans/b.pcap", "scans/c.csv.gz"}
+	contents := map[string][]byte{
+		"scans/a.pcap":   bytes.Repeat([]byte("a"), 100000),
+		"scans/b.pcap":   bytes.Repeat([]byte("b"), 100000),
+		"scans/c.csv.gz": gzipped(t, []byte("ip,port\n1.1.1.1,80\n")),
+	}
+	expected := map[string][]byte{
+		"/data/scan.tar.gz/scans/a.pcap":   contents["scans/a.pcap"],
+		"/data/scan.tar.gz/scans/b.pcap":   contents["scans/b.pcap"],
+		"/data/scan.tar.gz/scans/c.csv.gz": []byte("ip,port\n1.1.1.1,80\n"),
+		"/data/plain.csv":                  []byte("plain"),
+	}
+	tests := []struct {
+		name       string
+		concurrent bool
+	}{
+		{"sequential", false},
+		{"concurrent", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := NewDecompressSource(&memorySource{entries: []Entry{
+				&memoryEntry{path: "/data/scan.tar.gz", content: newTarGz(t, files, contents)},
+				&memoryEntry{path: "/data/plain.csv", content: []byte("plain")},
+			}})
+			// Collect all the entries before opening any of them, like the darknet importer
+			entries := make([]Entry, 0)
+			for entry := source.Next(); entry != nil; entry = source.Next() {
+				entries = append(entries, entry)
+			}
+			if len(entries) != len(expected) {
+				t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+			}
+			read := make(map[string][]byte)
+			var mutex sync.Mutex
+			var wg sync.WaitGroup
+			for i := len(entries) - 1; i >= 0; i-- {
+				entry := entries[i]
+				readEntry := func() {
+					defer wg.Done()
+					defer entry.Close()
+					reader, err := entry.Open()
+					if err != nil {
+						t.Errorf("cannot open %s: %s", entry.Path(), err)
+						return
+					}
+					content, err := ioutil.ReadAll(reader)
+					if err != nil {
+						t.Errorf("cannot read %s: %s", entry.Path(), err)
+						return
+					}
+					mutex.Lock()
+					read[entry.Path()] = content
+					mutex.Unlock()
+				}
+				wg.Add(1)
+				if test.concurrent {
+					go readEntry()
+				} else {
+					readEntry()
+				}
+			}
+			wg.Wait()
+			for entryPath, content := range expected {
+				if !bytes.Equal(read[entryPath], content) {
+					t.Errorf("wrong content for %s: got %d bytes, expected %d", entryPath, len(read[entryPath]), len(content))
+				}
+			}
+		})
+	}
+}
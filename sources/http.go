@@ -2,6 +2,8 @@ package sources
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/clcert/osr/logs"
@@ -12,29 +14,46 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// DefaultHTTPRetryWait is the time waited before the first retry of a failed request, if it's not defined in the config.
+const DefaultHTTPRetryWait = time.Second
+
 // HTTPConfig defines a configuration for a HTTP Source
 type HTTPConfig struct {
-	URL      string              // Initial URL to download
-	Filename string              // Name for initial URL
-	Username string              // If defined, BASIC username used to crawl the file
-	Password string              // If defined, BASIC password used to crawl the file
-	Method   string              // Method to use when retrieving the first file. It can be GET or POST
-	Body     map[string][]string // Body to send as body with the first file.
-	Filter   *FilterConfig       // Filter configuration
+	URL        string                // Initial URL to download
+	Filename   string                // Name for initial URL
+	Username   string                // If defined, BASIC username used to crawl the file
+	Password   string                // If defined, BASIC password used to crawl the file
+	Token      string                // If defined, token sent as a Bearer token on the Authorization header
+	Headers    map[string]string     // Headers sent on every request
+	Method     string                // Method to use when retrieving the first file. It can be GET or POST
+	Body       map[string][]string   // Body to send as body with the first file.
+	RateLimit  float64               // Max number of requests per second. If it's zero, there is no limit
+	Retries    int                   // Number of times a request is retried if it fails, or if the server answers 429 or 5xx
+	RetryWait  time.Duration         // Time waited before the first retry. It's doubled on each retry. By default, it's 1s
+	CacheFile  string                // If defined, file where the ETag and Last-Modified of the consumed files are stored. Unchanged files are skipped on the next executions. If it's relative, it's relative to OSR home folder
+	Pagination *HTTPPaginationConfig // If defined, the URL is a JSON API and each one of its pages is returned as an entry
+	Filter     *FilterConfig         // Filter configuration
 }
 
 // HTTPource defines a remote source of files, connected via HTTP.
 type HTTPSource struct {
-	*HTTPConfig           // Configuration
-	name   string         // Source name
-	filter *Filter        // Filter
-	files  chan *HTTPFile // Channel of files
-	log    *logs.OSRLog   // Source Log
-	params utils.Params   // Process Parameters
-	client *http.Client   // Client used in all requests
+	*HTTPConfig                 // Configuration
+	name        string          // Source name
+	filter      *Filter         // Filter
+	files       chan Entry      // Channel of files
+	log         *logs.OSRLog    // Source Log
+	params      utils.Params    // Process Parameters
+	client      *http.Client    // Client used in all requests
+	cache       *httpCache      // Validators of the files consumed, if the source uses a cache
+	mutex       sync.Mutex      // Protects the time of the last request
+	lastRequest time.Time       // Time when the last request was sent, used to limit the rate
+	ctx         context.Context // Context of the process using the source
 }
 
 // HTTPFile represents a HTTP entry source.
@@ -44,6 +63,7 @@ type HTTPFile struct {
 	url      *url.URL       // Complete file URL
 	body     url.Values     // Body to send when HTTPFile is retrieved
 	response *http.Response // Response body
+	data     []byte         // Content of the file, if it was read when it was retrieved (e.g. API pages)
 	buffer   io.Reader      // Buffered extReader
 	method   string         // Method to use when retrieving file
 }
@@ -76,7 +96,7 @@ func (config *HTTPConfig) New(name string, params utils.Params) (source *HTTPSou
 	source = &HTTPSource{
 		name:       name,
 		HTTPConfig: config,
-		files:      make(chan *HTTPFile),
+		files:      make(chan Entry),
 		filter:     filter,
 		log:        log,
 		params:     params,
@@ -95,11 +115,22 @@ func (config *HTTPConfig) Format(params utils.Params) error {
 	config.Username = params.FormatString(config.Username)
 	config.Password = params.FormatString(config.Password)
 	config.Method = params.FormatString(config.Method)
+	config.Token = params.FormatString(config.Token)
+	config.CacheFile = params.FormatString(config.CacheFile)
+	headers := make(map[string]string, len(config.Headers))
+	for name, value := range config.Headers {
+		headers[name] = params.FormatString(value)
+	}
+	config.Headers = headers
+	if config.Pagination != nil {
+		config.Pagination = config.Pagination.Format(params)
+	}
 	config.Filter = config.Filter.Format(params)
 	return nil
 }
 
-func (source *HTTPSource) Init() error {
+func (source *HTTPSource) Init(ctx context.Context) error {
+	source.ctx = ctx
 	id, err := source.GetID()
 	if err != nil {
 		id = fmt.Sprintf("cannot get id: %s", err)
@@ -112,10 +143,20 @@ func (source *HTTPSource) Init() error {
 	if source.URL == "" {
 		return fmt.Errorf("config mandatory fields not initialized")
 	}
+	if source.Pagination != nil && source.filter.Recursive {
+		return fmt.Errorf("pagination cannot be used with a recursive filter")
+	}
 	aUrl, err := url.Parse(source.URL)
 	if err != nil {
 		return err
 	}
+	if source.CacheFile != "" && source.Pagination == nil {
+		cache, err := loadHTTPCache(source.CacheFile)
+		if err != nil {
+			return err
+		}
+		source.cache = cache
+	}
 
 	rootFile := &HTTPFile{
 		source: source,
@@ -125,12 +166,18 @@ func (source *HTTPSource) Init() error {
 		method: source.Method,
 	}
 	go func() {
-		if source.filter == nil || !source.filter.Recursive {
+		if source.Pagination != nil {
+			source.log.WithFields(logrus.Fields{
+				"type": "HTTP",
+				"id":   id,
+			}).Info("Pagination enabled. Adding pages...")
+			source.retrievePages(rootFile)
+		} else if source.filter == nil || !source.filter.Recursive {
 			source.log.WithFields(logrus.Fields{
 				"type": "HTTP",
 				"id":   id,
 			}).Info("Adding page to channel and exiting")
-			source.files <- rootFile
+			source.sendFile(rootFile)
 		} else {
 			source.log.WithFields(logrus.Fields{
 				"type": "HTTP",
@@ -152,17 +199,21 @@ func (source *HTTPSource) GetID() (string, error) {
 }
 
 func (source *HTTPSource) Next() Entry {
-	entry, ok := <-source.files
-	if !ok {
-		return nil
-	}
-	return entry
+	return nextEntry(source.ctx, source.files)
 }
 
 func (source *HTTPSource) Close() error {
 	return nil
 }
 
+// Commit saves the validators of the consumed files, if the source uses a cache.
+func (source *HTTPSource) Commit() error {
+	if source.cache != nil {
+		return source.cache.save()
+	}
+	return nil
+}
+
 func (source *HTTPSource) GetName() string {
 	return source.name
 }
@@ -187,19 +238,22 @@ func (source *HTTPSource) retrieveFiles(httpFile *HTTPFile) {
 	visited := make(map[string]struct{})
 	// We get the response location, in case we were redirected
 	rootURL := httpFile.response.Request.URL
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+	doc.Find("a").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if source.ctx.Err() != nil {
+			return false
+		}
 		href, hasLink := s.Attr("href")
 		if hasLink {
 			innerText := s.Text()
 			aURL, err := rootURL.Parse(href)
 			if err != nil {
 				// TODO log this
-				return
+				return true
 			}
 			// Skip if the url returns back into the tree
 			if _, ok := visited[aURL.String()]; ok {
 				// TODO: log this
-				return
+				return true
 			}
 			// mark url as visited
 			visited[aURL.String()] = struct{}{}
@@ -213,45 +267,32 @@ func (source *HTTPSource) retrieveFiles(httpFile *HTTPFile) {
 				source.log.WithFields(logrus.Fields{
 					"Path": aFile.url.String(),
 				}).Info("Adding file...")
-				source.files <- aFile
-				return
+				return source.sendFile(aFile)
 			}
 			for _, regex := range source.filter.Patterns {
 				if regex.MatchString(aFile.Name()) {
 					source.log.WithFields(logrus.Fields{
 						"Path": aFile.Path(),
 					}).Info("Adding file...")
-					source.files <- aFile
-					return
+					return source.sendFile(aFile)
 				}
 			}
 		}
+		return true
 	})
 }
 
 func (srcFile *HTTPFile) Open() (reader io.Reader, err error) {
-	if srcFile.response == nil {
-		var req *http.Request
-		req, err = http.NewRequest(
-			srcFile.source.Method,
-			srcFile.url.String(),
-			strings.NewReader(srcFile.body.Encode()),
-		)
-		if err != nil {
-			return
-		}
-		if srcFile.body != nil {
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if srcFile.data != nil {
+		if srcFile.buffer == nil {
+			srcFile.buffer = bytes.NewReader(srcFile.data)
 		}
-		if srcFile.source.Username != "" && srcFile.source.Password != "" {
-			req.SetBasicAuth(srcFile.source.Username, srcFile.source.Password)
-		}
-		var file *http.Response
-		file, err = srcFile.source.client.Do(req)
-		if err != nil {
+		return srcFile.buffer, nil
+	}
+	if srcFile.response == nil {
+		if err = srcFile.fetch(); err != nil {
 			return
 		}
-		srcFile.response = file
 	}
 	if srcFile.buffer == nil {
 		srcFile.buffer = bufio.NewReader(srcFile.response.Body)
@@ -261,8 +302,22 @@ func (srcFile *HTTPFile) Open() (reader io.Reader, err error) {
 
 }
 
+// fetch sends the request of the file, using the validators stored in the cache of the source, if any.
+func (srcFile *HTTPFile) fetch() error {
+	header := make(http.Header)
+	if srcFile.source.cache != nil {
+		srcFile.source.cache.setValidators(srcFile.url.String(), header)
+	}
+	response, err := srcFile.source.do(srcFile.method, srcFile.url, srcFile.body, header)
+	if err != nil {
+		return err
+	}
+	srcFile.response = response
+	return nil
+}
+
 func (srcFile *HTTPFile) Name() string {
-	if srcFile.name == "" {
+	if srcFile.name == "" && srcFile.response != nil {
 		disposition := srcFile.response.Header.Get("content-disposition")
 		if len(disposition) > 0 {
 			filename := strings.Split(disposition, "filename=")
@@ -284,11 +339,124 @@ func (srcFile *HTTPFile) Path() string {
 	return srcFile.url.String()
 }
 
+// Close closes the response and, if the source uses a cache, stores its validators.
 func (srcFile *HTTPFile) Close() error {
+	srcFile.buffer = nil
 	if srcFile.response != nil {
+		if srcFile.source.cache != nil {
+			srcFile.source.cache.addValidators(srcFile.url.String(), srcFile.response.Header)
+		}
 		err := srcFile.response.Body.Close()
 		srcFile.response = nil
 		return err
 	}
 	return nil
 }
+
+// sendFile sends a file to the channel of the source. If the source uses a cache, the file is requested
+// first, and it's not sent if the server answers that it didn't change. It returns false if the source
+// should stop retrieving files.
+func (source *HTTPSource) sendFile(file *HTTPFile) bool {
+	if source.cache != nil {
+		if err := file.fetch(); err != nil {
+			source.log.WithFields(logrus.Fields{
+				"url": file.url.String(),
+			}).Errorf("Cannot retrieve file: %s", err)
+			return source.ctx == nil || source.ctx.Err() == nil
+		}
+		if file.response.StatusCode == http.StatusNotModified {
+			file.response.Body.Close()
+			source.log.WithFields(logrus.Fields{
+				"url": file.url.String(),
+			}).Info("File didn't change since it was consumed, skipping it")
+			return true
+		}
+	}
+	return sendEntry(source.ctx, source.files, file)
+}
+
+// do sends a request, adding the configured headers and authentication. It respects the rate limit
+// and retries the request if it fails or the server answers 429 or 5xx. The body is sent as a form.
+func (source *HTTPSource) do(method string, aURL *url.URL, body url.Values, header http.Header) (*http.Response, error) {
+	wait := source.RetryWait
+	if wait <= 0 {
+		wait = DefaultHTTPRetryWait
+	}
+	for attempt := 0; ; attempt++ {
+		if err := source.waitRate(); err != nil {
+			return nil, err
+		}
+		req, err := source.newRequest(method, aURL, body, header)
+		if err != nil {
+			return nil, err
+		}
+		response, err := source.client.Do(req)
+		if err == nil && response.StatusCode != http.StatusTooManyRequests && response.StatusCode < 500 {
+			return response, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected status: %s", response.Status)
+			if retryAfter, convErr := strconv.Atoi(response.Header.Get("Retry-After")); convErr == nil && retryAfter > 0 {
+				wait = time.Duration(retryAfter) * time.Second
+			}
+			response.Body.Close()
+		}
+		if attempt >= source.Retries || (source.ctx != nil && source.ctx.Err() != nil) {
+			return nil, err
+		}
+		source.log.WithFields(logrus.Fields{
+			"url":     aURL.String(),
+			"attempt": attempt + 1,
+			"wait":    wait,
+		}).Warnf("Request failed, retrying: %s", err)
+		if err := sleepContext(source.ctx, wait); err != nil {
+			return nil, err
+		}
+		wait *= 2
+	}
+}
+
+// newRequest creates a request with the headers and authentication of the source.
+func (source *HTTPSource) newRequest(method string, aURL *url.URL, body url.Values, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequest(method, aURL.String(), strings.NewReader(body.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	if source.ctx != nil {
+		req = req.WithContext(source.ctx)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if source.Username != "" && source.Password != "" {
+		req.SetBasicAuth(source.Username, source.Password)
+	}
+	if source.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+source.Token)
+	}
+	for name, value := range source.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	return req, nil
+}
+
+// waitRate waits until a new request can be sent without exceeding the rate limit of the source.
+func (source *HTTPSource) waitRate() error {
+	if source.RateLimit <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / source.RateLimit)
+	source.mutex.Lock()
+	next := source.lastRequest.Add(interval)
+	if now := time.Now(); next.Before(now) {
+		next = now
+	}
+	source.lastRequest = next
+	source.mutex.Unlock()
+	return sleepContext(source.ctx, time.Until(next))
+}
@@ -0,0 +1,101 @@
+package sources
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// httpCache stores the validators (ETag and Last-Modified headers) of the files consumed from a HTTP source,
+// so the next executions can ask the server to send them only if they changed.
+type httpCache struct {
+	path    string                     // Path of the cache file
+	mutex   sync.Mutex                 // Protects the entries
+	entries map[string]*httpCacheEntry // Validators, by URL
+	changed bool                       // True if there are validators not saved on the file
+}
+
+// httpCacheEntry contains the validators of a file.
+type httpCacheEntry struct {
+	ETag         string // ETag header of the response
+	LastModified string // Last-Modified header of the response
+}
+
+// loadHTTPCache reads a cache file. If it doesn't exist, it returns an empty cache.
+// Relative paths are relative to OSR home folder.
+func loadHTTPCache(path string) (*httpCache, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(viper.GetString("folders.home"), path)
+	}
+	cache := &httpCache{
+		path:    path,
+		entries: make(map[string]*httpCacheEntry),
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// setValidators adds to a request header the conditional headers for a URL, if it's in the cache.
+func (cache *httpCache) setValidators(url string, header http.Header) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, ok := cache.entries[url]
+	if !ok {
+		return
+	}
+	if entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// addValidators stores the validators of a response for a URL.
+func (cache *httpCache) addValidators(url string, header http.Header) {
+	entry := &httpCacheEntry{
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.entries[url] = entry
+	cache.changed = true
+}
+
+// save writes the cache on its file, if it changed.
+func (cache *httpCache) save() error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if !cache.changed {
+		return nil
+	}
+	content, err := json.MarshalIndent(cache.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cache.path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(cache.path, content, 0644); err != nil {
+		return err
+	}
+	cache.changed = false
+	return nil
+}
@@ -0,0 +1,166 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/clcert/osr/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// HTTPPaginationConfig defines how to retrieve the pages of a JSON API.
+// If NextField is defined, the source follows the next links of the pages. If not,
+// it changes the PageParam or OffsetParam of the URL until a page has no items.
+type HTTPPaginationConfig struct {
+	NextField   string // Dot separated path of the field with the URL of the next page (e.g. links.next). The pagination ends when it's empty
+	PageParam   string // Query param with the number of the page
+	FirstPage   int    // Number of the first page, if PageParam is used
+	OffsetParam string // Query param with the offset of the first item of the page
+	LimitParam  string // Query param with the number of items per page, if the API needs it
+	PageSize    int    // Number of items per page. If a page has less items, it's the last one
+	ItemsField  string // Dot separated path of the field with the items of the page. If it's empty, the page should be a JSON array
+	MaxPages    int    // Max number of pages to retrieve. If it's zero, there is no limit
+}
+
+// Format formats the configuration, using the params defined in the task
+func (config *HTTPPaginationConfig) Format(params utils.Params) *HTTPPaginationConfig {
+	return &HTTPPaginationConfig{
+		NextField:   params.FormatString(config.NextField),
+		PageParam:   params.FormatString(config.PageParam),
+		FirstPage:   config.FirstPage,
+		OffsetParam: params.FormatString(config.OffsetParam),
+		LimitParam:  params.FormatString(config.LimitParam),
+		PageSize:    config.PageSize,
+		ItemsField:  params.FormatString(config.ItemsField),
+		MaxPages:    config.MaxPages,
+	}
+}
+
+// pageURL returns the URL of a page, when the pagination uses page or offset params.
+func (config *HTTPPaginationConfig) pageURL(first *url.URL, page int) *url.URL {
+	pageURL := *first
+	query := pageURL.Query()
+	if config.PageParam != "" {
+		query.Set(config.PageParam, strconv.Itoa(config.FirstPage+page))
+	}
+	if config.OffsetParam != "" {
+		query.Set(config.OffsetParam, strconv.Itoa(page*config.PageSize))
+	}
+	if config.LimitParam != "" && config.PageSize > 0 {
+		query.Set(config.LimitParam, strconv.Itoa(config.PageSize))
+	}
+	pageURL.RawQuery = query.Encode()
+	return &pageURL
+}
+
+// retrievePages retrieves the pages of a JSON API, sending each one of them as an entry.
+// The first file defines the URL, method and body of the first page. The next pages are retrieved with GET.
+func (source *HTTPSource) retrievePages(first *HTTPFile) {
+	config := source.Pagination
+	if config.NextField == "" && config.PageParam == "" && config.OffsetParam == "" {
+		source.log.WithFields(logrus.Fields{
+			"url": first.url.String(),
+		}).Error("Pagination needs a next field, a page param or an offset param")
+		return
+	}
+	if config.OffsetParam != "" && config.PageSize <= 0 {
+		source.log.WithFields(logrus.Fields{
+			"url": first.url.String(),
+		}).Error("Pagination with an offset param needs a page size")
+		return
+	}
+	pageURL := first.url
+	if config.NextField == "" {
+		pageURL = config.pageURL(first.url, 0)
+	}
+	for page := 0; config.MaxPages <= 0 || page < config.MaxPages; page++ {
+		file := first
+		if page > 0 {
+			file = &HTTPFile{
+				source: source,
+				method: "GET",
+			}
+		}
+		file.url = pageURL
+		file.name = fmt.Sprintf("page-%d.json", page+1)
+		doc, err := file.readPage()
+		if err != nil {
+			source.log.WithFields(logrus.Fields{
+				"url":  pageURL.String(),
+				"page": page + 1,
+			}).Errorf("Cannot retrieve page, ending pagination: %s", err)
+			return
+		}
+		items, hasItems := jsonField(doc, config.ItemsField).([]interface{})
+		if config.NextField == "" && (!hasItems || len(items) == 0) {
+			// An empty page, after the last one
+			return
+		}
+		if !sendEntry(source.ctx, source.files, file) {
+			return
+		}
+		if config.NextField != "" {
+			next, _ := jsonField(doc, config.NextField).(string)
+			if next == "" {
+				return
+			}
+			pageURL, err = pageURL.Parse(next)
+			if err != nil {
+				source.log.WithFields(logrus.Fields{
+					"url":  file.url.String(),
+					"next": next,
+				}).Errorf("Invalid next page URL, ending pagination: %s", err)
+				return
+			}
+		} else {
+			if config.PageSize > 0 && len(items) < config.PageSize {
+				return
+			}
+			pageURL = config.pageURL(first.url, page+1)
+		}
+	}
+}
+
+// readPage retrieves the file, storing its content, and returns it parsed as JSON.
+func (srcFile *HTTPFile) readPage() (interface{}, error) {
+	if err := srcFile.fetch(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		srcFile.response.Body.Close()
+		srcFile.response = nil
+	}()
+	if srcFile.response.StatusCode >= 400 {
+		return nil, fmt.Errorf("unexpected status: %s", srcFile.response.Status)
+	}
+	data, err := ioutil.ReadAll(srcFile.response.Body)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %s", err)
+	}
+	srcFile.data = data
+	return doc, nil
+}
+
+// jsonField returns the value of a dot separated path on a parsed JSON document.
+// If the path is empty, it returns the document. If the field doesn't exist, it returns nil.
+func jsonField(doc interface{}, field string) interface{} {
+	if field == "" {
+		return doc
+	}
+	for _, key := range strings.Split(field, ".") {
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = object[key]
+	}
+	return doc
+}
@@ -0,0 +1,293 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// LocalConfig defines the configuration that a Local Source uses
+type LocalConfig struct {
+	Path     string        // Local path to walk. It can be a folder, a file or a glob pattern (e.g. /data/*/scan-*.csv)
+	Filter   *FilterConfig // Filter configuration
+	MoveDone bool          // If true, the files read are moved to the done folder when the process succeeds
+	DoneDir  string        // Folder where consumed files are moved. If it's relative, it's relative to the folder of each file. By default, it's "done"
+}
+
+// LocalSource represents a source of files in the local filesystem.
+type LocalSource struct {
+	*LocalConfig                 // Configuration
+	name         string          // Source name
+	filter       *Filter         // Filter to use on document finding
+	files        chan Entry      // Channel for files found.
+	log          *logs.OSRLog    // Logs
+	params       utils.Params    // Process Parameters
+	ctx          context.Context // Context of the process using the source
+	mutex        sync.Mutex      // Protects the list of closed files
+	closed       []string        // Paths of the files closed, moved to the done folder when the source is committed
+}
+
+// LocalFile represents a local file entry.
+type LocalFile struct {
+	source *LocalSource // Source of the file
+	path   string       // Path of the file
+	info   os.FileInfo  // File info, obtained when the file was found
+	file   *os.File     // Opened file
+	buffer io.Reader    // Buffered reader
+}
+
+// New creates a new local source from a configuration
+func (config *LocalConfig) New(name string, params utils.Params) (source *LocalSource, err error) {
+	if config.Filter == nil {
+		config.Filter = &FilterConfig{}
+	}
+	err = config.Format(params)
+	if err != nil {
+		return
+	}
+	var filter *Filter
+	filter, err = config.Filter.New()
+	if err != nil {
+		return
+	}
+	log, err := logs.NewLog(name)
+	if err != nil {
+		return
+	}
+	source = &LocalSource{
+		name:        name,
+		LocalConfig: config,
+		files:       make(chan Entry),
+		filter:      filter,
+		log:         log,
+		params:      params,
+	}
+	return
+}
+
+// Format formats the configuration, using the params defined in the task
+func (config *LocalConfig) Format(params utils.Params) error {
+	if params == nil {
+		// do nothing
+		return nil
+	}
+	config.Path = params.FormatString(config.Path)
+	config.DoneDir = params.FormatString(config.DoneDir)
+	config.Filter = config.Filter.Format(params)
+	return nil
+}
+
+func (source *LocalSource) Init(ctx context.Context) error {
+	source.ctx = ctx
+	if source.Path == "" {
+		return fmt.Errorf("mandatory config fields not initialized")
+	}
+	if source.DoneDir == "" {
+		source.DoneDir = "done"
+	}
+	roots, err := source.getRoots()
+	if err != nil {
+		return err
+	}
+	source.log.WithFields(logrus.Fields{
+		"type":   "Local",
+		"path":   source.Path,
+		"roots":  len(roots),
+		"filter": source.filter,
+	}).Info("Starting Local Source...")
+	go source.retrieveFiles(roots)
+	return nil
+}
+
+// getRoots returns the paths to walk. If the path of the source is a glob pattern,
+// they are the paths matching it. If not, it's only the path of the source.
+func (source *LocalSource) getRoots() ([]string, error) {
+	if !strings.ContainsAny(source.Path, "*?[") {
+		if _, err := os.Stat(source.Path); err != nil {
+			return nil, err
+		}
+		return []string{source.Path}, nil
+	}
+	roots, err := filepath.Glob(source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern: %s", err)
+	}
+	return roots, nil
+}
+
+func (source *LocalSource) retrieveFiles(roots []string) {
+	defer close(source.files)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				source.log.WithFields(logrus.Fields{
+					"path": path,
+				}).Errorf("Cannot walk path: %s", err)
+				return nil
+			}
+			if info.IsDir() {
+				if path != root && (!source.filter.Recursive || source.isDoneDir(path)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !source.matches(path) {
+				return nil
+			}
+			if !sendEntry(source.ctx, source.files, &LocalFile{
+				source: source,
+				path:   path,
+				info:   info,
+			}) {
+				return context.Canceled
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// matches returns true if the path matches the filter of the source.
+func (source *LocalSource) matches(path string) bool {
+	if len(source.filter.Patterns) == 0 {
+		return true
+	}
+	for _, regex := range source.filter.Patterns {
+		if regex.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDoneDir returns true if the folder is where the consumed files are moved.
+func (source *LocalSource) isDoneDir(dir string) bool {
+	if !source.MoveDone {
+		return false
+	}
+	if filepath.IsAbs(source.DoneDir) {
+		return filepath.Clean(dir) == filepath.Clean(source.DoneDir)
+	}
+	return filepath.Base(dir) == filepath.Base(source.DoneDir)
+}
+
+// moveDone moves a consumed file to the done folder.
+func (source *LocalSource) moveDone(path string) error {
+	doneDir := source.DoneDir
+	if !filepath.IsAbs(doneDir) {
+		doneDir = filepath.Join(filepath.Dir(path), doneDir)
+	}
+	if err := os.MkdirAll(doneDir, 0755); err != nil {
+		return err
+	}
+	donePath := filepath.Join(doneDir, filepath.Base(path))
+	if err := os.Rename(path, donePath); err != nil {
+		return err
+	}
+	source.log.WithFields(logrus.Fields{
+		"from": path,
+		"to":   donePath,
+	}).Info("File moved to done folder")
+	return nil
+}
+
+func (source *LocalSource) Next() Entry {
+	return nextEntry(source.ctx, source.files)
+}
+
+func (source *LocalSource) Close() error {
+	return nil
+}
+
+// Commit moves the files read to the done folder, if the source is configured to do it.
+// It tries to move all the files, returning the last error.
+func (source *LocalSource) Commit() (err error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	for _, path := range source.closed {
+		if moveErr := source.moveDone(path); moveErr != nil {
+			source.log.WithFields(logrus.Fields{
+				"path": path,
+			}).Errorf("Couldn't move file to done folder: %s", moveErr)
+			err = moveErr
+		}
+	}
+	source.closed = nil
+	return
+}
+
+func (source *LocalSource) GetID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", hostname, source.Path), nil
+}
+
+func (source *LocalSource) GetName() string {
+	return source.name
+}
+
+func (source *LocalSource) GetAttachments() []string {
+	return []string{source.log.Path}
+}
+
+func (srcFile *LocalFile) Open() (io.Reader, error) {
+	if srcFile.file == nil {
+		file, err := os.Open(srcFile.path)
+		if err != nil {
+			return nil, err
+		}
+		srcFile.file = file
+	}
+	if srcFile.buffer == nil {
+		srcFile.buffer = bufio.NewReader(srcFile.file)
+	}
+	return newContextReader(srcFile.source.ctx, srcFile.buffer), nil
+}
+
+func (srcFile *LocalFile) Name() string {
+	return filepath.Base(srcFile.path)
+}
+
+func (srcFile *LocalFile) Dir() string {
+	return filepath.Dir(srcFile.path)
+}
+
+func (srcFile *LocalFile) Path() string {
+	return srcFile.path
+}
+
+func (srcFile *LocalFile) Stat() (int64, time.Time) {
+	return srcFile.info.Size(), srcFile.info.ModTime()
+}
+
+// Close closes the file and, if the source is configured to do it, registers it to be moved to the done folder.
+func (srcFile *LocalFile) Close() error {
+	srcFile.buffer = nil
+	if srcFile.file == nil {
+		return nil
+	}
+	if err := srcFile.file.Close(); err != nil {
+		return err
+	}
+	srcFile.file = nil
+	if srcFile.source.MoveDone {
+		srcFile.source.mutex.Lock()
+		srcFile.source.closed = append(srcFile.source.closed, srcFile.path)
+		srcFile.source.mutex.Unlock()
+	}
+	return nil
+}
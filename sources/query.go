@@ -1,6 +1,7 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"path"
@@ -27,13 +28,15 @@ type QuerySource struct {
 	name   string          // Source name
 	log    *logs.OSRLog    // Source log file
 	params utils.Params    // Process Parameters
-	files  chan *QueryFile // Channel of files
+	files  chan Entry      // Channel of files
+	ctx    context.Context // Context of the process using the source
 }
 
 // QueryFile represents the command being executed.
 type QueryFile struct {
 	source *QuerySource
 	query  *query.Query
+	format string // Output format of the query
 	reader *io.PipeReader
 	writer *io.PipeWriter
 }
@@ -53,7 +56,7 @@ func (config *QueryListConfig) New(name string, params utils.Params) (source *Qu
 		QueryListConfig: config,
 		log:             log,
 		params:          params,
-		files:           make(chan *QueryFile),
+		files:           make(chan Entry),
 	}
 	return
 }
@@ -69,7 +72,8 @@ func (config *QueryListConfig) Format(params utils.Params) error {
 }
 
 // Init initializes the source.
-func (source *QuerySource) Init() error {
+func (source *QuerySource) Init(ctx context.Context) error {
+	source.ctx = ctx
 	if source.Queries == nil {
 		return fmt.Errorf("there are no queries to execute")
 	}
@@ -78,9 +82,14 @@ func (source *QuerySource) Init() error {
 	if err != nil {
 		return err
 	}
-	source.conn = db
+	if ctx != nil {
+		source.conn = db.WithContext(ctx)
+	} else {
+		source.conn = db
+	}
 
 	go func() {
+		defer close(source.files)
 		for _, queryConfig := range source.Queries {
 			queries, err := queryConfig.Open()
 			if err != nil {
@@ -93,25 +102,23 @@ func (source *QuerySource) Init() error {
 			}
 			for _, aQuery := range queries {
 				reader, writer := io.Pipe()
-				source.files <- &QueryFile{
+				if !sendEntry(source.ctx, source.files, &QueryFile{
 					source: source,
 					query:  aQuery,
+					format: queryConfig.Format,
 					reader: reader,
 					writer: writer,
+				}) {
+					return
 				}
 			}
 		}
-		close(source.files)
 	}()
 	return nil
 }
 
 func (source *QuerySource) Next() Entry {
-	entry, ok := <-source.files
-	if !ok {
-		return nil
-	}
-	return entry
+	return nextEntry(source.ctx, source.files)
 }
 
 func (source *QuerySource) Close() error {
@@ -145,14 +152,16 @@ func (source *QuerySource) GetAttachments() []string {
 
 func (srcFile *QueryFile) Open() (io.Reader, error) {
 	go func() {
-		chErr := srcFile.query.Export(srcFile.source.conn, srcFile.writer, true)
+		chErr := srcFile.query.ExportFormat(srcFile.source.conn, srcFile.writer, srcFile.format, true)
 		_ = srcFile.writer.CloseWithError(<-chErr)
 	}()
 	return srcFile.reader, nil
 }
 
 func (srcFile *QueryFile) Name() string {
-	return srcFile.query.Name + ".csv" // we are csv aren't we?
+	// The format was validated when the query file was opened
+	extension, _ := query.Extension(srcFile.format)
+	return srcFile.query.Name + extension
 }
 
 func (srcFile *QueryFile) Dir() string {
@@ -0,0 +1,227 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/remote"
+	"github.com/clcert/osr/utils"
+	"github.com/minio/minio-go/v7"
+	"github.com/sirupsen/logrus"
+)
+
+// S3Config defines the configuration that a S3 Source uses
+type S3Config struct {
+	ServerName string        // Name of the storage as declared in the s3 section of OSR config
+	Bucket     string        // Bucket with the objects
+	Prefix     string        // Prefix of the objects to list. Only the objects under it are returned
+	Filter     *FilterConfig // Filter configuration. Patterns are matched against the object keys
+}
+
+// S3Source represents a source of objects in a bucket of a S3 compatible storage.
+type S3Source struct {
+	*S3Config                  // Configuration
+	name      string           // Source name
+	server    *remote.S3Server // Storage configuration
+	client    *minio.Client    // Storage client
+	filter    *Filter          // Filter to use on object listing
+	files     chan Entry       // Channel for objects found.
+	log       *logs.OSRLog     // Logs
+	params    utils.Params     // Process Parameters
+	ctx       context.Context  // Context of the process using the source
+}
+
+// S3Object represents an object in a bucket.
+type S3Object struct {
+	source  *S3Source     // Source of the object
+	key     string        // Key of the object
+	size    int64         // Size of the object
+	modTime time.Time     // Last modification of the object
+	object  *minio.Object // Opened object
+	buffer  io.Reader     // Buffered reader
+}
+
+// New creates a new S3 source from a configuration
+func (config *S3Config) New(name string, params utils.Params) (source *S3Source, err error) {
+	if config.Filter == nil {
+		config.Filter = &FilterConfig{}
+	}
+	err = config.Format(params)
+	if err != nil {
+		return
+	}
+	var filter *Filter
+	filter, err = config.Filter.New()
+	if err != nil {
+		return
+	}
+	log, err := logs.NewLog(name)
+	if err != nil {
+		return
+	}
+	source = &S3Source{
+		name:     name,
+		S3Config: config,
+		files:    make(chan Entry),
+		filter:   filter,
+		log:      log,
+		params:   params,
+	}
+	return
+}
+
+// Format formats the configuration, using the params defined in the task
+func (config *S3Config) Format(params utils.Params) error {
+	if params == nil {
+		// do nothing
+		return nil
+	}
+	config.ServerName = params.FormatString(config.ServerName)
+	config.Bucket = params.FormatString(config.Bucket)
+	config.Prefix = params.FormatString(config.Prefix)
+	config.Filter = config.Filter.Format(params)
+	return nil
+}
+
+func (source *S3Source) Init(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	source.ctx = ctx
+	if source.ServerName == "" || source.Bucket == "" {
+		return fmt.Errorf("mandatory config fields not initialized")
+	}
+	server, err := remote.GetS3Server(source.ServerName)
+	if err != nil {
+		return err
+	}
+	client, err := server.NewClient()
+	if err != nil {
+		return err
+	}
+	source.server = server
+	source.client = client
+	source.log.WithFields(logrus.Fields{
+		"type":     "S3",
+		"endpoint": server.Endpoint,
+		"bucket":   source.Bucket,
+		"prefix":   source.Prefix,
+		"filter":   source.filter,
+	}).Info("Starting S3 Source...")
+	go source.retrieveObjects()
+	return nil
+}
+
+func (source *S3Source) retrieveObjects() {
+	defer close(source.files)
+	objects := source.client.ListObjects(source.ctx, source.Bucket, minio.ListObjectsOptions{
+		Prefix:    source.Prefix,
+		Recursive: source.filter.Recursive,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			source.log.WithFields(logrus.Fields{
+				"bucket": source.Bucket,
+				"prefix": source.Prefix,
+			}).Errorf("Cannot list objects: %s", object.Err)
+			return
+		}
+		if strings.HasSuffix(object.Key, "/") {
+			// It's a folder, returned when the listing is not recursive
+			continue
+		}
+		if !source.matches(object.Key) {
+			continue
+		}
+		if !sendEntry(source.ctx, source.files, &S3Object{
+			source:  source,
+			key:     object.Key,
+			size:    object.Size,
+			modTime: object.LastModified,
+		}) {
+			return
+		}
+	}
+}
+
+// matches returns true if the key matches the filter of the source.
+func (source *S3Source) matches(key string) bool {
+	if len(source.filter.Patterns) == 0 {
+		return true
+	}
+	for _, regex := range source.filter.Patterns {
+		if regex.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (source *S3Source) Next() Entry {
+	return nextEntry(source.ctx, source.files)
+}
+
+func (source *S3Source) Close() error {
+	return nil
+}
+
+func (source *S3Source) GetID() (string, error) {
+	if source.server == nil {
+		return "", fmt.Errorf("source not initialized")
+	}
+	return fmt.Sprintf("%s/%s/%s", source.server.Endpoint, source.Bucket, source.Prefix), nil
+}
+
+func (source *S3Source) GetName() string {
+	return source.name
+}
+
+func (source *S3Source) GetAttachments() []string {
+	return []string{source.log.Path}
+}
+
+func (srcFile *S3Object) Open() (io.Reader, error) {
+	if srcFile.object == nil {
+		object, err := srcFile.source.client.GetObject(srcFile.source.ctx, srcFile.source.Bucket, srcFile.key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		srcFile.object = object
+	}
+	if srcFile.buffer == nil {
+		srcFile.buffer = bufio.NewReader(srcFile.object)
+	}
+	return newContextReader(srcFile.source.ctx, srcFile.buffer), nil
+}
+
+func (srcFile *S3Object) Name() string {
+	return path.Base(srcFile.key)
+}
+
+func (srcFile *S3Object) Dir() string {
+	return path.Dir(srcFile.key)
+}
+
+func (srcFile *S3Object) Path() string {
+	return srcFile.key
+}
+
+func (srcFile *S3Object) Stat() (int64, time.Time) {
+	return srcFile.size, srcFile.modTime
+}
+
+func (srcFile *S3Object) Close() error {
+	srcFile.buffer = nil
+	if srcFile.object == nil {
+		return nil
+	}
+	err := srcFile.object.Close()
+	srcFile.object = nil
+	return err
+}
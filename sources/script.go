@@ -1,6 +1,7 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/query"
@@ -23,13 +24,14 @@ type ScriptConfig struct {
 
 // ScriptSource represents a source that is a command in execution.
 type ScriptSource struct {
-	*ScriptConfig           // Script Configuration
-	name     string         // Name of source
-	consumed bool           // True if the command was retrieved with next
-	server   *remote.Server // Remote server
-	file     *ScriptFile    // The Script itself, as a file.
-	log      *logs.OSRLog   // Source log file
-	params   utils.Params   // Process Parameters
+	*ScriptConfig                 // Script Configuration
+	name          string          // Name of source
+	consumed      bool            // True if the command was retrieved with next
+	server        *remote.Server  // Remote server
+	file          *ScriptFile     // The Script itself, as a file.
+	log           *logs.OSRLog    // Source log file
+	params        utils.Params    // Process Parameters
+	ctx           context.Context // Context of the process using the source
 }
 
 // ScriptFile represents the command being executed.
@@ -37,6 +39,7 @@ type ScriptFile struct {
 	source  *ScriptSource
 	script  string
 	session *ssh.Session
+	done    chan struct{} // Closed when the file is closed
 }
 
 // New creates a new ScriptSource from a ScriptConfig.
@@ -72,7 +75,8 @@ func (config *ScriptConfig) Format(params utils.Params) error {
 	return nil
 }
 
-func (source *ScriptSource) Init() error {
+func (source *ScriptSource) Init(ctx context.Context) error {
+	source.ctx = ctx
 	if source.ServerName == "" {
 		return fmt.Errorf("mandatory config fields not initialized")
 	}
@@ -125,6 +129,9 @@ func (source *ScriptSource) Init() error {
 }
 
 func (source *ScriptSource) Next() Entry {
+	if source.ctx != nil && source.ctx.Err() != nil {
+		return nil
+	}
 	if !source.consumed {
 		source.consumed = true
 		return source.file
@@ -176,7 +183,19 @@ func (srcFile *ScriptFile) Open() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	if srcFile.source.ctx != nil {
+		// Stop the remote command if the process is cancelled before it ends.
+		srcFile.done = make(chan struct{})
+		go func(session *ssh.Session, done chan struct{}) {
+			select {
+			case <-srcFile.source.ctx.Done():
+				_ = session.Signal(ssh.SIGTERM)
+				_ = session.Close()
+			case <-done:
+			}
+		}(srcFile.session, srcFile.done)
+	}
+	return newContextReader(srcFile.source.ctx, out), nil
 }
 
 func (srcFile *ScriptFile) Name() string {
@@ -194,6 +213,10 @@ func (srcFile *ScriptFile) Path() string {
 }
 
 func (srcFile *ScriptFile) Close() error {
+	if srcFile.done != nil {
+		close(srcFile.done)
+		srcFile.done = nil
+	}
 	if err := srcFile.session.Close(); err != nil {
 		if err != io.EOF {
 			return err
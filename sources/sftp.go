@@ -2,6 +2,7 @@ package sources
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/query"
@@ -9,7 +10,9 @@ import (
 	"github.com/clcert/osr/utils"
 	"github.com/pkg/sftp"
 	"io"
+	"os"
 	"path"
+	"time"
 )
 
 // TODO: More loging on this source
@@ -24,19 +27,21 @@ type SFTPConfig struct {
 
 // SFTPSource defines a remote source of files, connected via SFTP.
 type SFTPSource struct {
-	*SFTPConfig           // Configuration
-	name   string         // Source name
-	server *remote.Server // Server name as defined on OSR Config
-	filter *Filter        // Filter to use on document finding
-	files  chan Entry     // Channel for files found.
-	log    *logs.OSRLog   // Logs
-	params utils.Params   // Process Parameters
+	*SFTPConfig                 // Configuration
+	name        string          // Source name
+	server      *remote.Server  // Server name as defined on OSR Config
+	filter      *Filter         // Filter to use on document finding
+	files       chan Entry      // Channel for files found.
+	log         *logs.OSRLog    // Logs
+	params      utils.Params    // Process Parameters
+	ctx         context.Context // Context of the process using the source
 }
 
 // SFTPFile represents a SFTP entry source.
 type SFTPFile struct {
 	path   string      // Complete Path of the file
 	source *SFTPSource // Related source
+	info   os.FileInfo // File info, obtained when the file was found
 	file   *sftp.File  // File object
 	buffer io.Reader   // Buffered extReader
 }
@@ -83,7 +88,8 @@ func (config *SFTPConfig) Format(params utils.Params) error {
 	return nil
 }
 
-func (source *SFTPSource) Init() error {
+func (source *SFTPSource) Init(ctx context.Context) error {
+	source.ctx = ctx
 	if source.ServerName == "" || source.Path == "" {
 		return fmt.Errorf("mandatory config fields not initialized")
 	}
@@ -133,11 +139,7 @@ func (source *SFTPSource) Close() error {
 }
 
 func (source *SFTPSource) Next() Entry {
-	entry, ok := <-source.files
-	if !ok {
-		return nil
-	}
-	return entry
+	return nextEntry(source.ctx, source.files)
 }
 
 func (source *SFTPSource) retrieveFiles() {
@@ -161,13 +163,18 @@ func (source *SFTPSource) retrieveFiles() {
 			aFile := &SFTPFile{
 				source: source,
 				path:   walker.Path(),
+				info:   walker.Stat(),
 			}
 			if len(source.filter.Patterns) == 0 {
-				source.files <- aFile
+				if !sendEntry(source.ctx, source.files, aFile) {
+					return
+				}
 			}
 			for _, regex := range source.filter.Patterns {
 				if regex.MatchString(walker.Path()) {
-					source.files <- aFile
+					if !sendEntry(source.ctx, source.files, aFile) {
+						return
+					}
 					break
 				}
 			}
@@ -211,7 +218,7 @@ func (srcFile *SFTPFile) Open() (io.Reader, error) {
 	if srcFile.buffer == nil {
 		srcFile.buffer = bufio.NewReader(srcFile.file)
 	}
-	return srcFile.buffer, nil
+	return newContextReader(srcFile.source.ctx, srcFile.buffer), nil
 }
 
 func (srcFile *SFTPFile) Name() string {
@@ -226,6 +233,10 @@ func (srcFile *SFTPFile) Path() string {
 	return srcFile.path
 }
 
+func (srcFile *SFTPFile) Stat() (int64, time.Time) {
+	return srcFile.info.Size(), srcFile.info.ModTime()
+}
+
 func (srcFile *SFTPFile) Close() error {
 	srcFile.buffer = nil
 	return srcFile.file.Close()
@@ -11,6 +11,7 @@
 package sources
 
 import (
+	"context"
 	"fmt"
 	"github.com/clcert/osr/mailer"
 	"github.com/clcert/osr/utils"
@@ -20,11 +21,19 @@ import (
 )
 
 // It defines a source config. It can define only one from [SFTP, HTTP, Script, ...]
+
 type Config struct {
-	SFTP     *SFTPConfig      // Config if type is sftp
-	HTTP     *HTTPConfig      // Config if type is http
-	Script   *ScriptConfig    // Config if type is command
-	Query    *QueryListConfig // Config if type is query
+	Ref         string           // Name of a source defined in the sources section of the task file. If set, the other fields are ignored.
+	SFTP        *SFTPConfig      // Config if type is sftp
+	HTTP        *HTTPConfig      // Config if type is http
+	Script      *ScriptConfig    // Config if type is command
+	Query       *QueryListConfig // Config if type is query
+	Local       *LocalConfig     // Config if type is local
+	Zip         *ZipConfig       // Config if type is zip
+	S3          *S3Config        // Config if type is s3
+	Checksum    *ChecksumConfig  // If set, the entries are skipped if their checksums or signatures are not valid
+	Decompress  bool             // If true, compressed entries are decompressed and tar entries are expanded into their files
+	Incremental bool             // If true, the entries consumed on previous executions are skipped if they didn't change
 }
 
 // Source defines a stream of entries, related to an import.
@@ -33,8 +42,9 @@ type Config struct {
 // it needs to store them somewhere (an array of entries, for example).
 type Source interface {
 	mailer.Attachable // Attachments to mailer
-	// Init inits the source, connecting to it and starting the retrieval of entries
-	Init() error
+	// Init inits the source, connecting to it and starting the retrieval of entries.
+	// When the context is done, the source stops retrieving entries and Next returns nil.
+	Init(ctx context.Context) error
 	// GetName returns a given name of the source, based on task and process association. Two instances with the same source conf
 	// could have different IDs.
 	GetName() string
@@ -46,6 +56,23 @@ type Source interface {
 	Close() error
 }
 
+// CommitSource is implemented by the sources which keep state about the consumed entries (e.g. the
+// validators of a HTTP cache), that must be persisted only if the process which consumed them succeeded.
+type CommitSource interface {
+	Source
+	// Commit persists the state of the entries consumed by the process.
+	Commit() error
+}
+
+// Commit persists the state of the consumed entries of a source, if it keeps any.
+// The sources which wrap another one commit the wrapped source.
+func Commit(source Source) error {
+	if commitSource, ok := source.(CommitSource); ok {
+		return commitSource.Commit()
+	}
+	return nil
+}
+
 // An entry represents a single file/object in a source.
 // It has a name, a Path, a dir and it's openable and closeable.
 type Entry interface {
@@ -76,6 +103,26 @@ type Filter struct {
 // New creates a new Source based on a specific configuration.
 // It also sets a name to the source, for logging purposes.
 func (source *Config) New(name string, params utils.Params) (Source, error) {
+	newSource, err := source.newSource(name, params)
+	if err != nil {
+		return nil, err
+	}
+	// Checksums and signatures are computed on the original files, so they are verified before decompressing them
+	if source.Checksum != nil {
+		checked, err := source.Checksum.New(newSource, params)
+		if err != nil {
+			return nil, err
+		}
+		newSource = checked
+	}
+	if source.Decompress {
+		return NewDecompressSource(newSource), nil
+	}
+	return newSource, nil
+}
+
+// newSource creates the Source defined by the configuration, without wrapping it.
+func (source *Config) newSource(name string, params utils.Params) (Source, error) {
 	switch {
 	case source.SFTP != nil:
 		return source.SFTP.New(name, params)
@@ -85,8 +132,14 @@ func (source *Config) New(name string, params utils.Params) (Source, error) {
 		return source.Script.New(name, params)
 	case source.Query != nil:
 		return source.Query.New(name, params)
+	case source.Local != nil:
+		return source.Local.New(name, params)
+	case source.Zip != nil:
+		return source.Zip.New(name, params)
+	case source.S3 != nil:
+		return source.S3.New(name, params)
 	default:
-		return nil, fmt.Errorf("invalid source: no config defined (you need to define sftp, http, query or command config)")
+		return nil, fmt.Errorf("invalid source: no config defined (you need to define sftp, http, query, command, local, zip or s3 config)")
 	}
 }
 
@@ -0,0 +1,87 @@
+package sources
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// StatEntry is implemented by the entries which know their size and modification time without being read.
+type StatEntry interface {
+	Entry
+	// Stat returns the size in bytes and the modification time of the entry.
+	// If they are unknown, the modification time is zero.
+	Stat() (size int64, modTime time.Time)
+}
+
+// SpooledEntry wraps an entry whose content was copied to a temporary file, so it can be
+// checked before being read by a process. The temporary file is removed when the entry is closed.
+type SpooledEntry struct {
+	Entry            // Wrapped entry
+	file   *os.File  // Temporary file with the content of the entry
+	buffer io.Reader // Buffered reader of the temporary file
+}
+
+// Spool copies the content of an entry to a temporary file, writing it also on the writers
+// (e.g. hashes), and returns an entry which reads the copied content.
+// The wrapped entry is closed when the returned entry is closed.
+func Spool(entry Entry, writers ...io.Writer) (*SpooledEntry, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	file, err := ioutil.TempFile("", "osr-spool-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(io.MultiWriter(append(writers, file)...), reader); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &SpooledEntry{
+		Entry: entry,
+		file:  file,
+	}, nil
+}
+
+// Open returns a reader of the copied content.
+func (entry *SpooledEntry) Open() (io.Reader, error) {
+	if entry.buffer == nil {
+		entry.buffer = bufio.NewReader(entry.file)
+	}
+	return entry.buffer, nil
+}
+
+// Stat returns the size and modification time of the wrapped entry, if it knows them.
+func (entry *SpooledEntry) Stat() (int64, time.Time) {
+	if statEntry, ok := entry.Entry.(StatEntry); ok {
+		return statEntry.Stat()
+	}
+	return 0, time.Time{}
+}
+
+// rewind moves the reader of the copied content back to its beginning.
+func (entry *SpooledEntry) rewind() error {
+	entry.buffer = nil
+	_, err := entry.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// Close removes the temporary file and closes the wrapped entry.
+func (entry *SpooledEntry) Close() error {
+	entry.buffer = nil
+	if entry.file != nil {
+		entry.file.Close()
+		os.Remove(entry.file.Name())
+		entry.file = nil
+	}
+	return entry.Entry.Close()
+}
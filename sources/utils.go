@@ -25,9 +25,11 @@ func executeQueries(server *remote.Server, remotePath string, queryFiles query.C
 			// TODO: Log this
 			continue
 		}
+		// The format was validated when the query file was opened
+		extension, _ := query.Extension(queryConfig.Format)
 		formatted := make(map[string]*query.Query, 0)
 		for _, aQuery := range queries {
-			outPath := path.Join(remotePath, aQuery.Name+".csv")
+			outPath := path.Join(remotePath, aQuery.Name+extension)
 			outFile, err := sftpClient.Create(outPath)
 			if err != nil {
 				if outFile != nil {
@@ -41,7 +43,7 @@ func executeQueries(server *remote.Server, remotePath string, queryFiles query.C
 				return err
 			}
 			formatted[aQuery.Name] = aQuery
-			chErr := aQuery.Export(db, outFile, false);
+			chErr := aQuery.ExportFormat(db, outFile, queryConfig.Format, false)
 			if <-chErr != nil {
 				if outFile != nil {
 					outFile.Close()
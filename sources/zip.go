@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/utils"
@@ -12,6 +13,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 )
 
 // TODO: More loging on this source
@@ -23,14 +25,15 @@ type ZipConfig struct {
 
 // ZipSource represents a Zip file.
 type ZipSource struct {
-	*ZipConfig             // Script Configuration
-	name      string       // Source name
-	extReader io.Reader    // External reader to use instead of config file
-	zipReader *zip.Reader  // Zip reader
-	filter    *Filter      // Source filter
-	files     chan Entry   // Files channel
-	log       *logs.OSRLog // Source log
-	params    utils.Params // Process Parameters
+	*ZipConfig                 // Script Configuration
+	name       string          // Source name
+	extReader  io.Reader       // External reader to use instead of config file
+	zipReader  *zip.Reader     // Zip reader
+	filter     *Filter         // Source filter
+	files      chan Entry      // Files channel
+	log        *logs.OSRLog    // Source log
+	params     utils.Params    // Process Parameters
+	ctx        context.Context // Context of the process using the source
 }
 
 // ZipEntry represents a file inside the Zip
@@ -103,7 +106,8 @@ func (source ZipSource) newReaderAt(reader io.Reader) (io.ReaderAt, int64, error
 	return bytes.NewReader(fLen), int64(len(fLen)), nil
 }
 
-func (source *ZipSource) Init() (err error) {
+func (source *ZipSource) Init(ctx context.Context) (err error) {
+	source.ctx = ctx
 	if source.Path == "" && source.extReader == nil {
 		err = fmt.Errorf("mandatory config fields not initialized: you must initialize Path in config or extReader with NewFromEntry()")
 		return
@@ -115,6 +119,7 @@ func (source *ZipSource) Init() (err error) {
 		if err != nil {
 			return err
 		}
+		defer f.Close() // the file is read completely by newReaderAt
 		reader = f
 	} else {
 		reader = source.extReader
@@ -129,6 +134,7 @@ func (source *ZipSource) Init() (err error) {
 	}
 	source.zipReader = zipReader
 	go func() {
+		defer close(source.files)
 		for _, f := range zipReader.File {
 			if strings.Contains(f.Name, "/") && !source.filter.Recursive {
 				continue
@@ -138,28 +144,27 @@ func (source *ZipSource) Init() (err error) {
 				file:   f,
 			}
 			if len(source.filter.Patterns) == 0 {
-				source.files <- aFile
+				if !sendEntry(source.ctx, source.files, aFile) {
+					return
+				}
 			} else {
 				for _, regex := range source.filter.Patterns {
 					if regex.MatchString(f.Name) {
-						source.files <- aFile
+						if !sendEntry(source.ctx, source.files, aFile) {
+							return
+						}
 						break
 					}
 				}
 			}
 		}
-		close(source.files)
 	}()
 
 	return nil
 }
 
 func (source *ZipSource) Next() Entry {
-	entry, open := <-source.files
-	if !open {
-		return nil
-	}
-	return entry
+	return nextEntry(source.ctx, source.files)
 }
 
 func (source *ZipSource) Close() error {
@@ -192,7 +197,7 @@ func (srcFile *ZipEntry) Open() (reader io.Reader, err error) {
 		}
 		srcFile.buffer = bufio.NewReader(fileReader)
 	}
-	return srcFile.buffer, nil
+	return newContextReader(srcFile.source.ctx, srcFile.buffer), nil
 }
 
 func (srcFile *ZipEntry) Name() string {
@@ -207,6 +212,10 @@ func (srcFile *ZipEntry) Path() string {
 	return srcFile.file.Name
 }
 
+func (srcFile *ZipEntry) Stat() (int64, time.Time) {
+	return int64(srcFile.file.UncompressedSize64), srcFile.file.Modified
+}
+
 func (srcFile *ZipEntry) Close() error {
 	// Nothing to do in this case
 	return nil
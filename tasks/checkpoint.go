@@ -0,0 +1,294 @@
+package tasks
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/sources"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// entryKey identifies a source of a process in a task.
+type entryKey struct {
+	process int // Index of the process in the task
+	source  int // Index of the source in the process
+}
+
+// checkpoint stores on the database the execution records of the processes of a task and the
+// entries they consumed, allowing to resume the task if its execution is interrupted.
+// A nil checkpoint (used by incognito tasks) doesn't store anything.
+type checkpoint struct {
+	db        *pg.DB                           // DB writer
+	taskID    int                              // ID of the task session
+	processes map[int]*models.TaskProcess      // State of the processes on previous executions, by index
+	consumed  map[entryKey]map[string]struct{} // Paths of the entries consumed on previous executions
+}
+
+// checkpointSource wraps a source, skipping the entries consumed on previous executions of the
+// task and collecting the paths of the entries consumed by the process on this one.
+type checkpointSource struct {
+	sources.Source                     // Wrapped source
+	key            entryKey            // Process and source indexes
+	skip           map[string]struct{} // Paths of entries to skip
+	ctx            context.Context     // Context of the process using the source
+	mutex          sync.Mutex          // Protects closed list and read counter
+	closed         []string            // Paths of the entries consumed on this execution
+	read           int                 // Number of entries read on this execution
+}
+
+// checkpointEntry wraps an entry, notifying its source when it is closed after being read completely.
+type checkpointEntry struct {
+	sources.Entry                   // Wrapped entry
+	source        *checkpointSource // Source of the entry
+	reader        *eofReader        // Reader of the entry, available after opening it
+}
+
+// eofReader knows if the content of a reader was read completely.
+type eofReader struct {
+	reader io.Reader // Wrapped reader
+	eof    bool      // True if the content was read completely
+}
+
+// newCheckpoint returns a new checkpoint for a task session. If resume is true, it loads the state
+// of the previous executions of the session.
+func newCheckpoint(db *pg.DB, taskID int, resume bool) (*checkpoint, error) {
+	c := &checkpoint{
+		db:        db,
+		taskID:    taskID,
+		processes: make(map[int]*models.TaskProcess),
+		consumed:  make(map[entryKey]map[string]struct{}),
+	}
+	if !resume {
+		return c, nil
+	}
+	processes, err := models.GetTaskProcesses(db, taskID)
+	if err != nil {
+		return nil, err
+	}
+	for _, process := range processes {
+		c.processes[process.ProcessIndex] = process
+	}
+	entries, err := models.GetTaskEntries(db, taskID)
+	if err != nil {
+		return nil, err
+	}
+	c.load(entries)
+	return c, nil
+}
+
+// load adds entries consumed on previous executions, so they are skipped on this one.
+func (c *checkpoint) load(entries []*models.TaskEntry) {
+	for _, entry := range entries {
+		key := entryKey{process: entry.ProcessIndex, source: entry.SourceIndex}
+		if _, ok := c.consumed[key]; !ok {
+			c.consumed[key] = make(map[string]struct{})
+		}
+		c.consumed[key][entry.Path] = struct{}{}
+	}
+}
+
+// succeeded returns true if the process with the given index and command succeeded
+// on a previous execution of the task.
+func (c *checkpoint) succeeded(index int, command string) bool {
+	if c == nil {
+		return false
+	}
+	process, ok := c.processes[index]
+	return ok && process.Command == command && process.Status == models.SUCCEEDED
+}
+
+// setStatus saves the status of a process.
+func (c *checkpoint) setStatus(index int, command string, status models.ProcessStatus) {
+	if c == nil {
+		return
+	}
+	c.saveProcess(&models.TaskProcess{
+		TaskID:       c.taskID,
+		ProcessIndex: index,
+		Command:      command,
+		Status:       status,
+	})
+}
+
+// start saves a process as running and returns its execution record.
+func (c *checkpoint) start(index int, command string) *models.TaskProcess {
+	if c == nil {
+		return nil
+	}
+	process := &models.TaskProcess{
+		TaskID:       c.taskID,
+		ProcessIndex: index,
+		Command:      command,
+		StartDate:    time.Now(),
+		Status:       models.RUNNING,
+	}
+	c.saveProcess(process)
+	return process
+}
+
+// finish saves the result of a process execution on its record, counting the entries read
+// from its sources and the rows saved and errored by its savers. It should be called after the savers finished.
+func (c *checkpoint) finish(process *models.TaskProcess, args *Context, err error) {
+	if c == nil || process == nil {
+		return
+	}
+	process.EndDate = time.Now()
+	if err != nil {
+		process.Status = models.FAILED
+		process.Error = err.Error()
+	} else {
+		process.Status = models.SUCCEEDED
+	}
+	if args != nil {
+		process.Params = args.Params
+		for _, source := range args.Sources {
+			if cSource, ok := source.(*checkpointSource); ok {
+				cSource.mutex.Lock()
+				process.EntriesRead += cSource.read
+				cSource.mutex.Unlock()
+			}
+		}
+		for _, saver := range args.Savers {
+			process.RowsInserted += saver.GetInserted()
+		}
+		process.SaverStats = getSaverStats(args.Savers)
+		for _, saverStats := range process.SaverStats {
+			for _, outIDStats := range saverStats {
+				process.RowsErrored += outIDStats.Errors
+			}
+		}
+	}
+	c.saveProcess(process)
+}
+
+// saveProcess saves the execution record of a process, logging the error if it fails.
+func (c *checkpoint) saveProcess(process *models.TaskProcess) {
+	if err := process.Save(c.db); err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"task_id": c.taskID,
+			"index":   process.ProcessIndex,
+			"command": process.Command,
+			"status":  process.Status,
+		}).Errorf("Couldn't save process status: %s", err)
+	}
+}
+
+// wrap returns a source which skips the entries consumed on previous executions.
+// The entries closed after ctx is done are not registered as consumed.
+func (c *checkpoint) wrap(ctx context.Context, source sources.Source, processIndex, sourceIndex int) sources.Source {
+	if c == nil {
+		return source
+	}
+	key := entryKey{process: processIndex, source: sourceIndex}
+	skip, ok := c.consumed[key]
+	if !ok {
+		skip = make(map[string]struct{})
+	}
+	return &checkpointSource{
+		Source: source,
+		key:    key,
+		skip:   skip,
+		ctx:    ctx,
+		closed: make([]string, 0),
+	}
+}
+
+// save saves the entries consumed on a wrapped source. It should be called
+// after the savers of the process finished, so the data of the entries is already stored.
+func (c *checkpoint) save(source sources.Source) {
+	if c == nil {
+		return
+	}
+	cSource, ok := source.(*checkpointSource)
+	if !ok {
+		return
+	}
+	entries := c.takeConsumed(cSource)
+	if err := models.SaveTaskEntries(c.db, entries); err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"task_id": c.taskID,
+			"index":   cSource.key.process,
+			"source":  cSource.key.source,
+		}).Errorf("Couldn't save consumed entries: %s", err)
+	}
+}
+
+// takeConsumed returns the records of the entries consumed on a wrapped source, and empties its list.
+func (c *checkpoint) takeConsumed(source *checkpointSource) []*models.TaskEntry {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	entries := make([]*models.TaskEntry, len(source.closed))
+	for i, path := range source.closed {
+		entries[i] = &models.TaskEntry{
+			TaskID:       c.taskID,
+			ProcessIndex: source.key.process,
+			SourceIndex:  source.key.source,
+			Path:         path,
+		}
+	}
+	source.closed = source.closed[:0]
+	return entries
+}
+
+// Next returns the next entry not consumed on a previous execution.
+func (source *checkpointSource) Next() sources.Entry {
+	for {
+		entry := source.Source.Next()
+		if entry == nil {
+			return nil
+		}
+		if _, ok := source.skip[entry.Path()]; ok {
+			logs.Log.WithFields(logrus.Fields{
+				"source": source.GetName(),
+				"path":   entry.Path(),
+			}).Info("Skipping entry consumed on a previous execution")
+			continue
+		}
+		source.mutex.Lock()
+		source.read++
+		source.mutex.Unlock()
+		return &checkpointEntry{
+			Entry:  entry,
+			source: source,
+		}
+	}
+}
+
+// Open opens the wrapped entry, returning a reader which knows if the entry was read completely.
+func (entry *checkpointEntry) Open() (io.Reader, error) {
+	reader, err := entry.Entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	if entry.reader == nil || entry.reader.reader != reader {
+		entry.reader = &eofReader{reader: reader}
+	}
+	return entry.reader, nil
+}
+
+// Close closes the entry and marks it as consumed if the process finished reading it.
+// The entries which were not read completely, or which were closed after the process was
+// cancelled, are read again when the task is resumed.
+func (entry *checkpointEntry) Close() error {
+	err := entry.Entry.Close()
+	if err != nil || entry.reader == nil || !entry.reader.eof || entry.source.ctx.Err() != nil {
+		return err
+	}
+	entry.source.mutex.Lock()
+	entry.source.closed = append(entry.source.closed, entry.Path())
+	entry.source.mutex.Unlock()
+	return nil
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
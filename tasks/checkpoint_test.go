@@ -0,0 +1,206 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/savers"
+	"github.com/clcert/osr/sources"
+	"github.com/sirupsen/logrus"
+)
+
+// testEntry is an entry whose content is kept in memory.
+type testEntry struct {
+	path    string
+	content []byte
+	reader  io.Reader
+}
+
+func (entry *testEntry) Open() (io.Reader, error) {
+	if entry.reader == nil {
+		entry.reader = bytes.NewReader(entry.content)
+	}
+	return entry.reader, nil
+}
+func (entry *testEntry) Name() string { return path.Base(entry.path) }
+func (entry *testEntry) Path() string { return entry.path }
+func (entry *testEntry) Dir() string  { return path.Dir(entry.path) }
+func (entry *testEntry) Close() error { entry.reader = nil; return nil }
+
+// testSource is a source which returns entries with the given paths.
+type testSource struct {
+	entries []sources.Entry
+}
+
+func newTestSource(paths ...string) *testSource {
+	source := &testSource{}
+	for _, entryPath := range paths {
+		source.entries = append(source.entries, &testEntry{path: entryPath, content: []byte("content of " + entryPath)})
+	}
+	return source
+}
+
+func (source *testSource) GetAttachments() []string       { return nil }
+func (source *testSource) Init(ctx context.Context) error { return nil }
+func (source *testSource) GetName() string                { return "test" }
+func (source *testSource) GetID() (string, error)         { return "test", nil }
+func (source *testSource) Close() error                   { return nil }
+
+func (source *testSource) Next() sources.Entry {
+	if len(source.entries) == 0 {
+		return nil
+	}
+	entry := source.entries[0]
+	source.entries = source.entries[1:]
+	return entry
+}
+
+// testSaver is a saver which only counts the objects it receives, and the errors set by the tests.
+type testSaver struct {
+	errors []error
+	stats  models.SaverStats
+}
+
+func (saver *testSaver) GetAttachments() []string          { return nil }
+func (saver *testSaver) GetName() string                   { return "test" }
+func (saver *testSaver) Start(ctx context.Context) error   { return nil }
+func (saver *testSaver) Finish() error                     { return nil }
+func (saver *testSaver) SendMessage(msg interface{}) error { return nil }
+func (saver *testSaver) GetErrors() []error                { return saver.errors }
+func (saver *testSaver) GetInserted() int                  { return 0 }
+func (saver *testSaver) GetStats() models.SaverStats       { return saver.stats }
+func (saver *testSaver) Save(objs ...interface{}) error    { return nil }
+
+// readEntry reads an entry completely, or only its first byte if partial is true, and closes it.
+func readEntry(t *testing.T, entry sources.Entry, partial bool) {
+	reader, err := entry.Open()
+	if err != nil {
+		t.Fatalf("cannot open %s: %s", entry.Path(), err)
+	}
+	if partial {
+		_, err = reader.Read(make([]byte, 1))
+	} else {
+		_, err = ioutil.ReadAll(reader)
+	}
+	if err != nil {
+		t.Fatalf("cannot read %s: %s", entry.Path(), err)
+	}
+	if err := entry.Close(); err != nil {
+		t.Fatalf("cannot close %s: %s", entry.Path(), err)
+	}
+}
+
+func TestCheckpointResumeAfterFailure(t *testing.T) {
+	logs.Log = &logs.OSRLog{Logger: logrus.New()}
+	paths := []string{"/data/a.csv", "/data/b.csv", "/data/c.csv", "/data/d.csv"}
+	tests := []struct {
+		name    string
+		run     func(t *testing.T, source sources.Source, cancel context.CancelFunc)
+		resumed []string
+	}{
+		{
+			name: "failure while reading an entry",
+			run: func(t *testing.T, source sources.Source, cancel context.CancelFunc) {
+				readEntry(t, source.Next(), false)
+				readEntry(t, source.Next(), false)
+				// The process fails in the middle of the third entry, closing it
+				readEntry(t, source.Next(), true)
+			},
+			resumed: []string{"/data/c.csv", "/data/d.csv"},
+		},
+		{
+			name: "entries closed without reading them",
+			run: func(t *testing.T, source sources.Source, cancel context.CancelFunc) {
+				readEntry(t, source.Next(), false)
+				for entry := source.Next(); entry != nil; entry = source.Next() {
+					_ = entry.Close()
+				}
+			},
+			resumed: []string{"/data/b.csv", "/data/c.csv", "/data/d.csv"},
+		},
+		{
+			name: "cancelled before closing a read entry",
+			run: func(t *testing.T, source sources.Source, cancel context.CancelFunc) {
+				readEntry(t, source.Next(), false)
+				entry := source.Next()
+				if _, err := ioutil.ReadAll(mustOpen(t, entry)); err != nil {
+					t.Fatal(err)
+				}
+				// The objects of the entry may have been rejected by the savers after the cancellation
+				cancel()
+				_ = entry.Close()
+			},
+			resumed: []string{"/data/b.csv", "/data/c.csv", "/data/d.csv"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first := &checkpoint{taskID: 1, consumed: make(map[entryKey]map[string]struct{})}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			source := first.wrap(ctx, newTestSource(paths...), 0, 0)
+			test.run(t, source, cancel)
+			consumed := first.takeConsumed(source.(*checkpointSource))
+
+			// The task is resumed with the entries saved by the first execution
+			resumed := &checkpoint{taskID: 1, consumed: make(map[entryKey]map[string]struct{})}
+			resumed.load(consumed)
+			source = resumed.wrap(context.Background(), newTestSource(paths...), 0, 0)
+			read := make([]string, 0)
+			for entry := source.Next(); entry != nil; entry = source.Next() {
+				read = append(read, entry.Path())
+			}
+			if !reflect.DeepEqual(read, test.resumed) {
+				t.Errorf("expected to read %v when resuming, got %v", test.resumed, read)
+			}
+		})
+	}
+}
+
+func mustOpen(t *testing.T, entry sources.Entry) io.Reader {
+	reader, err := entry.Open()
+	if err != nil {
+		t.Fatalf("cannot open %s: %s", entry.Path(), err)
+	}
+	return reader
+}
+
+func TestSaversHadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		savers []*testSaver
+		errors bool
+	}{
+		{"no savers", nil, false},
+		{"savers without errors", []*testSaver{
+			{stats: models.SaverStats{"a": {Inserted: 10}}},
+			{stats: models.SaverStats{}},
+		}, false},
+		{"errored rows", []*testSaver{
+			{stats: models.SaverStats{"a": {Inserted: 10}}},
+			{stats: models.SaverStats{"b": {Inserted: 9, Errors: 1}}},
+		}, true},
+		{"error without rows", []*testSaver{
+			{errors: []error{fmt.Errorf("cannot finish")}, stats: models.SaverStats{}},
+		}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saverList := make([]savers.Saver, len(test.savers))
+			for i, saver := range test.savers {
+				saverList[i] = saver
+			}
+			if errors := saversHadErrors(saverList); errors != test.errors {
+				t.Errorf("expected %t, got %t", test.errors, errors)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package tasks
 
 import (
+	"context"
+
 	"github.com/clcert/osr/logs"
 	"github.com/clcert/osr/models"
 	"github.com/clcert/osr/savers"
@@ -17,6 +19,7 @@ type Context struct {
 	Savers  []savers.Saver   // A list of savers used to store the processed data
 	Params  utils.Params     // A list of args
 	Log     *logs.OSRLog     // Log used in the process(es) execution
+	Ctx     context.Context  // Cancelled when the task is aborted. Long processes should stop when it's done.
 }
 
 // AddSources adds more sources to an args list
@@ -48,3 +51,8 @@ func (context *Context) GetSourceID() models.DataSourceID {
 func (context *Context) GetTaskID() int {
 	return context.Task.TaskSession.ID
 }
+
+// IsCancelled returns true if the process was cancelled and it should stop as soon as possible.
+func (context *Context) IsCancelled() bool {
+	return context.Ctx != nil && context.Ctx.Err() != nil
+}
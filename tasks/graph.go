@@ -0,0 +1,72 @@
+package tasks
+
+import "fmt"
+
+// dependencies returns, for each process of the task, the indexes of the processes
+// it depends on. A dependency is defined by a command name, so if a command appears
+// more than once in the task, the dependent process waits for all its instances.
+func (config *TaskConfig) dependencies() ([][]int, error) {
+	deps := make([][]int, len(config.Processes))
+	for i, process := range config.Processes {
+		deps[i] = make([]int, 0)
+		for _, command := range process.DependsOn {
+			found := false
+			for j, other := range config.Processes {
+				if other.Command != command {
+					continue
+				}
+				if i == j {
+					return nil, fmt.Errorf("process %s (index %d) depends on itself", process.Command, i)
+				}
+				deps[i] = append(deps[i], j)
+				found = true
+			}
+			if !found {
+				return nil, fmt.Errorf("process %s (index %d) depends on %s, which is not defined in the task", process.Command, i, command)
+			}
+		}
+	}
+	return deps, nil
+}
+
+// topologicalOrder returns the indexes of the processes of the task sorted in a way that
+// every process is after all of its dependencies. Processes without dependencies between
+// them keep the order defined in the task file. It returns an error if the dependencies
+// have a cycle.
+func (config *TaskConfig) topologicalOrder() ([]int, error) {
+	deps, err := config.dependencies()
+	if err != nil {
+		return nil, err
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(deps))
+	order := make([]int, 0, len(deps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle found on process %s (index %d)", config.Processes[i].Command, i)
+		}
+		state[i] = visiting
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		order = append(order, i)
+		return nil
+	}
+	for i := range deps {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/clcert/osr/savers"
+	"github.com/clcert/osr/sources"
+	"github.com/clcert/osr/utils"
+	"github.com/spf13/viper"
+)
+
+// loadConfig reads a task file from the tasks folder and adds to it the params and the named sources
+// and savers of the files it includes. The definitions of a file have preference over the ones of the
+// files it includes, and the definitions of an included file have preference over the ones included before it.
+// Parents is the list of files which included this one, used to detect include cycles.
+func loadConfig(name string, parents []string) (*TaskConfig, error) {
+	for _, parent := range parents {
+		if parent == name {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(parents, " -> "), name)
+		}
+	}
+	taskRoot, err := GetTasksPath()
+	if err != nil {
+		return nil, err
+	}
+	viperConfig := viper.New()
+	viperConfig.SetConfigFile(path.Join(taskRoot, name))
+	if err := viperConfig.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var config TaskConfig
+	if err := viperConfig.Unmarshal(&config); err != nil {
+		return nil, err
+	}
+	params := make(utils.Params)
+	namedSources := make(map[string]*sources.Config)
+	namedSavers := make(map[string]*savers.Config)
+	for _, includeName := range config.Include {
+		included, err := loadConfig(includeName, append(parents, name))
+		if err != nil {
+			return nil, fmt.Errorf("cannot include %s: %s", includeName, err)
+		}
+		params = params.Join(included.Params)
+		for sourceName, source := range included.Sources {
+			namedSources[sourceName] = source
+		}
+		for saverName, saver := range included.Savers {
+			namedSavers[saverName] = saver
+		}
+	}
+	config.Params = params.Join(config.Params)
+	for sourceName, source := range config.Sources {
+		namedSources[sourceName] = source
+	}
+	for saverName, saver := range config.Savers {
+		namedSavers[saverName] = saver
+	}
+	config.Sources = namedSources
+	config.Savers = namedSavers
+	return &config, nil
+}
+
+// resolveRefs replaces the sources and savers of the processes which reference a named source or saver
+// with a copy of it. Each process receives its own copy, because the configs are formatted with the params
+// of the process when they are used. Names are case insensitive, because viper lowercases the keys of the file.
+func (config *TaskConfig) resolveRefs() error {
+	for i, process := range config.Processes {
+		for j, source := range process.Sources {
+			if source.Ref == "" {
+				continue
+			}
+			named, ok := config.Sources[strings.ToLower(source.Ref)]
+			if !ok || named == nil {
+				return fmt.Errorf("process %d (%s): source %d references an undefined source: %s", i, process.Command, j, source.Ref)
+			}
+			if named.Ref != "" {
+				return fmt.Errorf("named source %s cannot reference another source", source.Ref)
+			}
+			var resolved sources.Config
+			if err := copyConfig(named, &resolved); err != nil {
+				return fmt.Errorf("cannot copy source %s: %s", source.Ref, err)
+			}
+			process.Sources[j] = resolved
+		}
+		for j, saver := range process.Savers {
+			if saver.Ref == "" {
+				continue
+			}
+			named, ok := config.Savers[strings.ToLower(saver.Ref)]
+			if !ok || named == nil {
+				return fmt.Errorf("process %d (%s): saver %d references an undefined saver: %s", i, process.Command, j, saver.Ref)
+			}
+			if named.Ref != "" {
+				return fmt.Errorf("named saver %s cannot reference another saver", saver.Ref)
+			}
+			var resolved savers.Config
+			if err := copyConfig(named, &resolved); err != nil {
+				return fmt.Errorf("cannot copy saver %s: %s", saver.Ref, err)
+			}
+			process.Savers[j] = resolved
+		}
+	}
+	return nil
+}
+
+// copyConfig copies deeply a source or saver config into another one.
+func copyConfig(from, to interface{}) error {
+	encoded, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, to)
+}
@@ -0,0 +1,221 @@
+package tasks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/sources"
+	"github.com/go-pg/pg/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// incrementalSource wraps a source, skipping the entries which didn't change since they were consumed
+// on a previous execution, and collecting the state of the entries consumed on this one.
+// An entry didn't change if its size and modification time are the same. If the source doesn't know
+// them, or only the modification time changed, the entry is copied to a temporary file and its content hash is compared.
+type incrementalSource struct {
+	sources.Source                                  // Wrapped source
+	db             *pg.DB                           // DB writer
+	taskID         int                              // ID of the task session consuming the entries
+	id             string                           // ID of the wrapped source
+	consumed       map[string]*models.ConsumedEntry // State of the entries consumed on previous executions, by path
+	mutex          sync.Mutex                       // Protects closed list
+	closed         []*models.ConsumedEntry          // State of the entries closed on this execution
+}
+
+// incrementalEntry wraps an entry, hashing its content while it's read and
+// registering its state on its source when it is closed.
+type incrementalEntry struct {
+	sources.Entry                       // Wrapped entry
+	source        *incrementalSource    // Source of the entry
+	state         *models.ConsumedEntry // State of the entry
+	hash          hash.Hash             // Hash of the content read, or nil if it was already computed
+	reader        *hashReader           // Reader which computes the hash
+}
+
+// hashReader writes on a hash the content read, and knows if it was read completely.
+type hashReader struct {
+	reader io.Reader // Wrapped reader
+	hash   hash.Hash // Hash of the content read
+	eof    bool      // True if the content was read completely
+}
+
+// newIncrementalSource returns a source which skips the entries consumed on previous executions
+// that didn't change.
+func newIncrementalSource(db *pg.DB, taskID int, source sources.Source) *incrementalSource {
+	return &incrementalSource{
+		Source: source,
+		db:     db,
+		taskID: taskID,
+		closed: make([]*models.ConsumedEntry, 0),
+	}
+}
+
+// Init inits the wrapped source and loads the state of the entries consumed on previous executions.
+// The state is loaded after the wrapped source is initialized, because some sources need it to know their ID.
+func (source *incrementalSource) Init(ctx context.Context) error {
+	if err := source.Source.Init(ctx); err != nil {
+		return err
+	}
+	id, err := source.GetID()
+	if err != nil {
+		return err
+	}
+	consumed, err := models.GetConsumedEntries(source.db, id)
+	if err != nil {
+		return err
+	}
+	source.id = id
+	source.consumed = consumed
+	logs.Log.WithFields(logrus.Fields{
+		"source":   source.GetName(),
+		"id":       id,
+		"consumed": len(consumed),
+	}).Info("Loaded incremental source state")
+	return nil
+}
+
+// Next returns the next entry which changed or was not consumed on a previous execution.
+func (source *incrementalSource) Next() sources.Entry {
+	for {
+		entry := source.Source.Next()
+		if entry == nil {
+			return nil
+		}
+		state := &models.ConsumedEntry{
+			SourceID: source.id,
+			Path:     entry.Path(),
+		}
+		if statEntry, ok := entry.(sources.StatEntry); ok {
+			size, modTime := statEntry.Stat()
+			state.Size = size
+			// The DB stores times with microsecond precision
+			state.ModTime = modTime.Truncate(time.Microsecond)
+		}
+		previous, ok := source.consumed[entry.Path()]
+		if !ok {
+			return source.newEntry(entry, state)
+		}
+		if !state.ModTime.IsZero() {
+			if state.Size != previous.Size {
+				return source.newEntry(entry, state)
+			}
+			if state.ModTime.Equal(previous.ModTime) {
+				source.logSkip(entry, "size and modification time didn't change")
+				continue
+			}
+		}
+		if previous.Hash == "" {
+			return source.newEntry(entry, state)
+		}
+		contentHash := sha256.New()
+		spooled, err := sources.Spool(entry, contentHash)
+		if err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"source": source.GetName(),
+				"path":   entry.Path(),
+			}).Errorf("Couldn't read entry to compare its content, skipping it: %s", err)
+			_ = entry.Close()
+			continue
+		}
+		state.Hash = hex.EncodeToString(contentHash.Sum(nil))
+		if state.Hash == previous.Hash {
+			source.logSkip(entry, "content didn't change")
+			// The new modification time is saved, so the content is not compared again
+			source.addClosed(state)
+			_ = spooled.Close()
+			continue
+		}
+		return &incrementalEntry{
+			Entry:  spooled,
+			source: source,
+			state:  state,
+		}
+	}
+}
+
+// newEntry returns an entry which hashes its content while it's read.
+func (source *incrementalSource) newEntry(entry sources.Entry, state *models.ConsumedEntry) *incrementalEntry {
+	return &incrementalEntry{
+		Entry:  entry,
+		source: source,
+		state:  state,
+		hash:   sha256.New(),
+	}
+}
+
+// logSkip logs that an entry was skipped because it didn't change.
+func (source *incrementalSource) logSkip(entry sources.Entry, reason string) {
+	logs.Log.WithFields(logrus.Fields{
+		"source": source.GetName(),
+		"path":   entry.Path(),
+	}).Infof("Skipping entry consumed on a previous execution: %s", reason)
+}
+
+// addClosed registers the state of an entry consumed on this execution.
+func (source *incrementalSource) addClosed(state *models.ConsumedEntry) {
+	state.ConsumedAt = time.Now()
+	state.TaskID = source.taskID
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.closed = append(source.closed, state)
+}
+
+// save saves the state of the entries consumed on this execution. It should be called
+// only if the process succeeded, after its savers finished.
+func (source *incrementalSource) save() {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if err := models.SaveConsumedEntries(source.db, source.closed); err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"source": source.GetName(),
+			"id":     source.id,
+		}).Errorf("Couldn't save incremental source state: %s", err)
+	}
+	source.closed = source.closed[:0]
+}
+
+// Open opens the entry, returning a reader which hashes the content read.
+func (entry *incrementalEntry) Open() (io.Reader, error) {
+	reader, err := entry.Entry.Open()
+	if err != nil || entry.hash == nil {
+		return reader, err
+	}
+	if entry.reader == nil {
+		entry.reader = &hashReader{
+			hash: entry.hash,
+		}
+	}
+	// Entries opened again return readers of the same content, so the hash continues
+	entry.reader.reader = reader
+	return entry.reader, nil
+}
+
+// Close closes the entry and registers its state. The hash is only registered if the content was read completely.
+func (entry *incrementalEntry) Close() error {
+	err := entry.Entry.Close()
+	if err != nil {
+		return err
+	}
+	if entry.reader != nil && entry.reader.eof {
+		entry.state.Hash = hex.EncodeToString(entry.hash.Sum(nil))
+	}
+	entry.source.addClosed(entry.state)
+	return nil
+}
+
+func (r *hashReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
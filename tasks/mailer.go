@@ -20,6 +20,7 @@ Descripción Tarea:				{{ .Description }}
 Número Tarea:					{{ .TaskSession.ID }}
 Procesos satisfactorios:		{{ .GetSucceeded }}
 Procesos con error:				{{ .GetFailed }}
+Procesos omitidos:				{{ .GetSkipped }}
 Fecha Inicio: 					{{ .TaskSession.StartDate.Format "02-01-2006 15:04:05 -0700"}}
 Fecha Fin:						{{ .TaskSession.EndDate.Format "02-01-2006 15:04:05 -0700"}}
 Estado Final:					{{ .TaskSession.GetStatus }}
@@ -35,6 +36,16 @@ Error: {{ $err }}
 {{end}}
 {{end}}
 
+{{with .GetSaverStats}}
+A continuación, mostramos las filas guardadas y con error por cada saver de los procesos, según su outID:
+
+{{range $name, $saverStats := . }}
+Proceso: {{ $name }}
+{{range $index, $stats := $saverStats }}{{range $outID, $outIDStats := $stats }}Saver {{ $index }}, outID {{ $outID }}:	{{ $outIDStats.Inserted }} guardadas, {{ $outIDStats.Errors }} con error
+{{end}}{{end}}
+{{end}}
+{{end}}
+
 Adjuntamos también los archivos de log asociados a esta sesión de importación.
 
 Saludos!
@@ -60,6 +71,9 @@ func notify(task *Task) {
 	if len(task.GetFailed()) > 0 {
 		fields["failed"] = task.GetFailed()
 	}
+	if len(task.GetSkipped()) > 0 {
+		fields["skipped"] = task.GetSkipped()
+	}
 	logs.Log.WithFields(fields).Info("Task finished, sending mail...")
 	var level mailer.NotifyLevel
 	if task.HasErrors() {
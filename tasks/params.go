@@ -0,0 +1,130 @@
+package tasks
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clcert/osr/utils"
+)
+
+// ParamType defines the type of the value of a process param.
+type ParamType int
+
+// This constants represent the types a process param can have.
+const (
+	StringParam   ParamType = iota // Any string
+	IntParam                       // An integer number
+	FloatParam                     // A decimal number
+	BoolParam                      // A boolean value (true, false, 1, 0)
+	DateParam                      // A date, using the layout of the param spec
+	DurationParam                  // A duration (e.g. 30s, 2h)
+	IPParam                        // An IP address
+	ListParam                      // A comma separated list of strings
+	IntListParam                   // A comma separated list of integer numbers
+)
+
+// DefaultDateLayout is the layout used by date params if their spec doesn't define one.
+const DefaultDateLayout = "2006-01-02"
+
+var paramTypeToString = map[ParamType]string{
+	StringParam:   "string",
+	IntParam:      "int",
+	FloatParam:    "float",
+	BoolParam:     "bool",
+	DateParam:     "date",
+	DurationParam: "duration",
+	IPParam:       "ip",
+	ListParam:     "list",
+	IntListParam:  "int list",
+}
+
+// ParamSpec defines a param accepted by a process.
+type ParamSpec struct {
+	Name        string    // Name of the param
+	Type        ParamType // Type of the param value
+	Default     string    // If not empty, value used when the param is not defined
+	Required    bool      // If true, the process fails if the param is not defined and it has no default value
+	Layout      string    // Layout of the value if the param is a date. If empty, DefaultDateLayout is used
+	Description string    // A description of the param
+}
+
+// ParamSchema defines the params accepted by a process.
+// Params not defined in the schema are ignored, because task params are shared by all the processes.
+type ParamSchema []*ParamSpec
+
+// String returns a human readable name for the type.
+func (paramType ParamType) String() string {
+	return paramTypeToString[paramType]
+}
+
+// TypeName returns the name of the type of the param, including the layout for date params.
+func (spec *ParamSpec) TypeName() string {
+	if spec.Type == DateParam {
+		return fmt.Sprintf("%s (%s)", spec.Type, spec.GetLayout())
+	}
+	return spec.Type.String()
+}
+
+// GetLayout returns the layout used to parse a date param.
+func (spec *ParamSpec) GetLayout() string {
+	if spec.Layout == "" {
+		return DefaultDateLayout
+	}
+	return spec.Layout
+}
+
+// Check returns an error if the value is not valid for the type of the param.
+func (spec *ParamSpec) Check(value string) (err error) {
+	switch spec.Type {
+	case IntParam:
+		_, err = strconv.Atoi(value)
+	case FloatParam:
+		_, err = strconv.ParseFloat(value, 64)
+	case BoolParam:
+		_, err = strconv.ParseBool(value)
+	case DateParam:
+		_, err = time.Parse(spec.GetLayout(), value)
+	case DurationParam:
+		_, err = time.ParseDuration(value)
+	case IPParam:
+		if net.ParseIP(value) == nil {
+			err = fmt.Errorf("invalid IP address: %s", value)
+		}
+	case IntListParam:
+		for _, item := range strings.Split(value, ",") {
+			if _, err = strconv.Atoi(strings.TrimSpace(item)); err != nil {
+				break
+			}
+		}
+	}
+	return
+}
+
+// Validate checks the params against the schema, returning a copy of them with the default values
+// of the params not defined. It returns an error listing all the required params not defined and
+// the params with invalid values.
+func (schema ParamSchema) Validate(params utils.Params) (utils.Params, error) {
+	validated := params.Join(nil)
+	problems := make([]string, 0)
+	for _, spec := range schema {
+		value, ok := params[spec.Name]
+		if !ok {
+			if spec.Default != "" {
+				validated[spec.Name] = spec.Default
+			} else if spec.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", spec.Name))
+			}
+			continue
+		}
+		if err := spec.Check(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s should be %s: %s", spec.Name, spec.TypeName(), err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid params: %s", strings.Join(problems, "; "))
+	}
+	return validated, nil
+}
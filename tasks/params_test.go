@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clcert/osr/utils"
+)
+
+func TestParamSpecCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  *ParamSpec
+		value string
+		valid bool
+	}{
+		{"string", &ParamSpec{Type: StringParam}, "anything, really", true},
+		{"int", &ParamSpec{Type: IntParam}, "-42", true},
+		{"int with decimals", &ParamSpec{Type: IntParam}, "4.2", false},
+		{"int with letters", &ParamSpec{Type: IntParam}, "4two", false},
+		{"float", &ParamSpec{Type: FloatParam}, "0.5", true},
+		{"float with letters", &ParamSpec{Type: FloatParam}, "half", false},
+		{"bool", &ParamSpec{Type: BoolParam}, "true", true},
+		{"bool as number", &ParamSpec{Type: BoolParam}, "0", true},
+		{"bool with other word", &ParamSpec{Type: BoolParam}, "yes", false},
+		{"date with default layout", &ParamSpec{Type: DateParam}, "2024-02-29", true},
+		{"invalid date with default layout", &ParamSpec{Type: DateParam}, "2023-02-29", false},
+		{"date with other layout", &ParamSpec{Type: DateParam}, "29/02/2024", false},
+		{"date with custom layout", &ParamSpec{Type: DateParam, Layout: "02/01/2006"}, "29/02/2024", true},
+		{"duration", &ParamSpec{Type: DurationParam}, "1h30m", true},
+		{"duration without unit", &ParamSpec{Type: DurationParam}, "90", false},
+		{"ipv4", &ParamSpec{Type: IPParam}, "192.0.2.1", true},
+		{"ipv6", &ParamSpec{Type: IPParam}, "2001:db8::1", true},
+		{"subnet as ip", &ParamSpec{Type: IPParam}, "192.0.2.0/24", false},
+		{"list", &ParamSpec{Type: ListParam}, "a,b,c", true},
+		{"int list", &ParamSpec{Type: IntListParam}, "80, 443,8080", true},
+		{"int list with a string", &ParamSpec{Type: IntListParam}, "80,https", false},
+		{"int list with an empty item", &ParamSpec{Type: IntListParam}, "80,,443", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.spec.Check(test.value)
+			if test.valid && err != nil {
+				t.Errorf("expected %q to be valid, got %s", test.value, err)
+			} else if !test.valid && err == nil {
+				t.Errorf("expected %q to be invalid", test.value)
+			}
+		})
+	}
+}
+
+func TestParamSchemaValidate(t *testing.T) {
+	schema := ParamSchema{
+		{Name: "date", Type: DateParam, Required: true},
+		{Name: "workers", Type: IntParam, Default: "1"},
+		{Name: "ports", Type: IntListParam},
+	}
+	tests := []struct {
+		name      string
+		params    utils.Params
+		validated utils.Params
+		problems  []string
+	}{
+		{
+			name:      "valid params are kept",
+			params:    utils.Params{"date": "2024-01-31", "workers": "4", "ports": "80,443"},
+			validated: utils.Params{"date": "2024-01-31", "workers": "4", "ports": "80,443"},
+		},
+		{
+			name:      "defaults are added and optional params can be missing",
+			params:    utils.Params{"date": "2024-01-31"},
+			validated: utils.Params{"date": "2024-01-31", "workers": "1"},
+		},
+		{
+			name:      "params not in the schema are ignored",
+			params:    utils.Params{"date": "2024-01-31", "other": "x"},
+			validated: utils.Params{"date": "2024-01-31", "workers": "1", "other": "x"},
+		},
+		{
+			name:     "required param missing",
+			params:   utils.Params{"workers": "2"},
+			problems: []string{"date is required"},
+		},
+		{
+			name:     "all the problems are listed",
+			params:   utils.Params{"date": "31-01-2024", "workers": "many", "ports": "80,http"},
+			problems: []string{"date should be date (2006-01-02)", "workers should be int", "ports should be int list"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validated, err := schema.Validate(test.params)
+			if len(test.problems) > 0 {
+				if err == nil {
+					t.Fatalf("expected an error, got params %v", validated)
+				}
+				for _, problem := range test.problems {
+					if !strings.Contains(err.Error(), problem) {
+						t.Errorf("expected error to contain %q, got %s", problem, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(validated, test.validated) {
+				t.Errorf("expected %v, got %v", test.validated, validated)
+			}
+		})
+	}
+}
+
+func TestParamSchemaValidateDoesNotChangeParams(t *testing.T) {
+	schema := ParamSchema{{Name: "workers", Type: IntParam, Default: "1"}}
+	params := utils.Params{"date": "2024-01-31"}
+	if _, err := schema.Validate(params); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := params["workers"]; ok {
+		t.Error("expected the default value to be added only to the validated copy")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/clcert/osr/sources"
 	"github.com/clcert/osr/utils"
 	"strings"
+	"time"
 )
 
 type Processes map[string]*Process
@@ -19,11 +20,14 @@ const InfiniteSources = -1
 
 // ProcessConfig defines the configuration specific for a process
 type ProcessConfig struct {
-	Command  string              // Name of the command
-	SourceID models.DataSourceID // If set, overrides task source ID
-	Sources  []sources.Config    // List of sources related to the command
-	Savers   []savers.Config     // List of savers related to the command
-	Params   utils.Params        // List of specific params. They override the params of the global file.
+	Command             string              // Name of the command
+	SourceID            models.DataSourceID // If set, overrides task source ID
+	Sources             []sources.Config    // List of sources related to the command
+	Savers              []savers.Config     // List of savers related to the command
+	Params              utils.Params        // List of specific params. They override the params of the global file.
+	DependsOn           []string            // Commands of processes that must succeed before executing this one.
+	Timeout             time.Duration       // If positive, the process is cancelled when it runs for longer than this.
+	SaverErrorThreshold int                 // If positive, the process fails if its savers couldn't save at least this number of objects (the sum of the errors of each outID).
 }
 
 // Process defines completely a Task.
@@ -38,6 +42,7 @@ type Process struct {
 	Execute         func(*Context) error // An action to be executed when this command is called.
 	NumSources      int                  // Number of allowed sources on this task. If negative, it's unlimited.
 	NumSavers       int                  // Number of allowed savers on this task. If negative, it's unlimited.
+	Params          ParamSchema          // Params accepted by the process. They are validated before executing it.
 }
 
 // Registers a process to the global dictionary.
@@ -71,6 +76,65 @@ func (process *Process) NewArgs(task *Task, index int) (*Context, error) {
 	return context, nil
 }
 
+// GetType returns the type of the process (import, transform, export), based on the prefix of its command.
+func (process *Process) GetType() string {
+	return strings.SplitN(process.Command, "/", 2)[0]
+}
+
+// Skeleton returns a task file in YAML format with only this process, with stubs for its
+// sources, savers and params. The stubs must be completed before executing the task.
+func (process *Process) Skeleton() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\n", process.GetSafeName())
+	fmt.Fprintf(&b, "description: %q\n", process.Description)
+	b.WriteString("params: {}\n")
+	b.WriteString("processes:\n")
+	fmt.Fprintf(&b, "  - command: %s\n", process.Command)
+	if len(process.Params) > 0 {
+		b.WriteString("    params:\n")
+		for _, spec := range process.Params {
+			comment := "# "
+			if spec.Required {
+				comment = ""
+			}
+			fmt.Fprintf(&b, "      %s%s: %q # %s. %s\n", comment, spec.Name, spec.Default, spec.TypeName(), spec.Description)
+		}
+	}
+	numSources := process.NumSources
+	if numSources < 0 {
+		numSources = 1
+		b.WriteString("    # This process accepts any number of sources\n")
+	}
+	if numSources == 0 {
+		b.WriteString("    sources: []\n")
+	} else {
+		b.WriteString("    sources:\n")
+		for i := 0; i < numSources; i++ {
+			b.WriteString("      - sftp: # or http, script, query, local, zip, s3\n")
+			b.WriteString("          servername: \"\"\n")
+			b.WriteString("          path: \"\"\n")
+			b.WriteString("          filter:\n")
+			b.WriteString("            recursive: false\n")
+			b.WriteString("            patterns: []\n")
+		}
+	}
+	numSavers := process.NumSavers
+	if numSavers < 0 {
+		numSavers = 1
+		b.WriteString("    # This process accepts any number of savers\n")
+	}
+	if numSavers == 0 {
+		b.WriteString("    savers: []\n")
+	} else {
+		b.WriteString("    savers:\n")
+		for i := 0; i < numSavers; i++ {
+			b.WriteString("      - postgres: # or sftp, s3, clickhouse, local\n")
+			b.WriteString("          buffer: 1024\n")
+		}
+	}
+	return b.String()
+}
+
 func (process *Process) GetSafeName() string {
 	return strings.Replace(process.Command, "/", "-", -1)
 }
@@ -34,5 +34,8 @@ func Execute(args *tasks.Context) error {
 		}
 	}()
 	wg.Wait()
+	if args.IsCancelled() {
+		return fmt.Errorf("process cancelled: %s", args.Ctx.Err())
+	}
 	return nil
 }
@@ -0,0 +1,54 @@
+package simple
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// rowReader returns the rows of a file as maps from column names to values.
+type rowReader interface {
+	NextRow() (map[string]string, error)
+}
+
+// jsonlReader reads the rows of a JSON Lines file, like the ones exported by queries with jsonl format.
+type jsonlReader struct {
+	decoder *json.Decoder
+}
+
+func newJSONLReader(reader io.Reader) *jsonlReader {
+	decoder := json.NewDecoder(reader)
+	// Numbers are kept as they were written, so big integers don't lose precision
+	decoder.UseNumber()
+	return &jsonlReader{
+		decoder: decoder,
+	}
+}
+
+// NextRow returns the next JSON object as a map. Nested values are returned as JSON, and null values as empty strings.
+func (r *jsonlReader) NextRow() (map[string]string, error) {
+	object := make(map[string]interface{})
+	if err := r.decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	row := make(map[string]string, len(object))
+	for key, value := range object {
+		switch v := value.(type) {
+		case nil:
+			row[key] = ""
+		case string:
+			row[key] = v
+		case json.Number:
+			row[key] = v.String()
+		case bool:
+			row[key] = fmt.Sprintf("%t", v)
+		default:
+			nested, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			row[key] = string(nested)
+		}
+	}
+	return row, nil
+}
@@ -8,12 +8,17 @@ import (
 	"github.com/clcert/osr/utils"
 	"github.com/sirupsen/logrus"
 	"io"
+	"strings"
 	"sync"
 )
 
 func process(entryChan chan sources.Entry, saver savers.Saver, wg *sync.WaitGroup, args *tasks.Context) {
 	defer wg.Done()
 	for file := range entryChan {
+		// The remaining files are left unread and open, so they are not marked as consumed
+		if args.IsCancelled() {
+			continue
+		}
 		logs.Log.WithFields(logrus.Fields{
 			"file": file.Path(),
 		}).Info("opening file")
@@ -24,15 +29,20 @@ func process(entryChan chan sources.Entry, saver savers.Saver, wg *sync.WaitGrou
 			}).Error("couldn't open file: %s", err)
 			continue
 		}
-		csv, err := utils.NewHeadedCSV(reader, nil)
-		if err != nil {
-			logs.Log.WithFields(logrus.Fields{
-				"file": file.Path(),
-			}).Error("couldn't use file as csv: %s", err)
-			continue
+		var csv rowReader
+		if strings.HasSuffix(file.Name(), ".jsonl") {
+			csv = newJSONLReader(reader)
+		} else {
+			csv, err = utils.NewHeadedCSV(reader, nil)
+			if err != nil {
+				logs.Log.WithFields(logrus.Fields{
+					"file": file.Path(),
+				}).Error("couldn't use file as csv: %s", err)
+				continue
+			}
 		}
 
-		for {
+		for !args.IsCancelled() {
 			line, err := csv.NextRow()
 			if err != nil {
 				if err == io.EOF {
@@ -57,6 +67,15 @@ func process(entryChan chan sources.Entry, saver savers.Saver, wg *sync.WaitGrou
 				}).Error("cannot save line: %s", err)
 			}
 		}
+		// A file whose export was cancelled is not closed, so it is exported again later
+		if args.IsCancelled() {
+			continue
+		}
+		if err := file.Close(); err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"file": file.Path(),
+			}).Errorf("cannot close file: %s", err)
+		}
 	}
 	logs.Log.WithFields(logrus.Fields{
 	}).Info("thread done!")
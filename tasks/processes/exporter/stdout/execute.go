@@ -27,10 +27,14 @@ func Execute(args *tasks.Context) error {
 			return err
 		}
 		scanner := bufio.NewScanner(reader)
-		for scanner.Scan() {
+		for !args.IsCancelled() && scanner.Scan() {
 			line := scanner.Text()
 			fmt.Println(line)
 		}
+		// A file whose export was cancelled is not closed, so it is exported again later
+		if args.IsCancelled() {
+			return fmt.Errorf("process cancelled: %s", args.Ctx.Err())
+		}
 		if err := file.Close(); err != nil {
 			return err
 		}
@@ -44,7 +44,7 @@ func Execute(args *tasks.Context) error {
 			}).Error("Cannot open file as zip")
 			return err
 		}
-		err = zipSource.Init()
+		err = zipSource.Init(args.Ctx)
 		if err != nil {
 			return err
 		}
@@ -61,5 +61,8 @@ func Execute(args *tasks.Context) error {
 		go worker(i+1, &wg, fileChannel, saver, args)
 	}
 	wg.Wait()
+	if args.IsCancelled() {
+		return fmt.Errorf("process cancelled: %s", args.Ctx.Err())
+	}
 	return nil
 }
@@ -14,9 +14,13 @@ import (
 
 // worker this assigns a file to each worker jobs is used as queue containing all the files to be processed.
 // All the files processed are moved to "scanned" folder.
+// If the process is cancelled, the remaining files are left unread and open, so they are not marked as consumed.
 func worker(id int, wg *sync.WaitGroup, jobs chan sources.Entry, saver savers.Saver, args *tasks.Context) error {
 	defer wg.Done()
 	for entry := range jobs {
+		if args.IsCancelled() {
+			continue
+		}
 		packetsSeen := NewPacketDictionary(saver)
 		packetsSeen.SetArgs(args)
 		msg := fmt.Sprintf("Reading file: %s\n", entry.Name())
@@ -36,12 +40,18 @@ func worker(id int, wg *sync.WaitGroup, jobs chan sources.Entry, saver savers.Sa
 
 // readFromFile read a entry containing a pcap file (compressed or uncompressed)
 // It process the packets in the file using the PacketDict
-func readFromFile(f sources.Entry, packetsSeen *PacketDict, filter string) error {
+// If the process is cancelled while reading the file, the entry is not closed, so it is read again when the task is resumed.
+func readFromFile(entry sources.Entry, packetsSeen *PacketDict, filter string) error {
+	f := sources.NewDecompressedEntry(entry)
 	pcapReader, err := f.Open()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer func() {
+		if !packetsSeen.Args.IsCancelled() {
+			_ = f.Close()
+		}
+	}()
 	reader, err := pcapgo.NewReader(pcapReader)
 	if err != nil {
 		msg := fmt.Sprintf("Couldn't open reader for file %s\n", f.Path())
@@ -75,6 +85,9 @@ func readFromFile(f sources.Entry, packetsSeen *PacketDict, filter string) error
 		return err
 	}
 	for packet := range packetSource.Packets() {
+		if packetsSeen.Args.IsCancelled() {
+			return fmt.Errorf("process cancelled while reading file %s", f.Path())
+		}
 		ci = packet.Metadata().CaptureInfo
 		data = packet.Data()
 		if len(data) == 0 {
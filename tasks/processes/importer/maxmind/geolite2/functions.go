@@ -60,7 +60,7 @@ func parseSource(source sources.Source, saver savers.Saver, args *tasks.Context)
 			}
 			return
 		}
-		err = zipSource.Init()
+		err = zipSource.Init(args.Ctx)
 		if err != nil {
 			args.Log.WithFields(logrus.Fields{
 				"path":   entry.Path(),
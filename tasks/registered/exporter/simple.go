@@ -16,6 +16,14 @@ func init() {
 			Execute:         simple.Execute,
 			NumSources:      -1,
 			NumSavers:       1,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "threads",
+					Type:        tasks.IntParam,
+					Default:     "1",
+					Description: "Number of entries processed at the same time",
+				},
+			},
 		},
 	)
 }
@@ -17,6 +17,13 @@ func init() {
 			Execute:         rankings.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "tlds",
+					Type:        tasks.ListParam,
+					Description: "Comma separated list of TLDs of the domains to import",
+				},
+			},
 		},
 	)
 }
@@ -18,6 +18,7 @@ func init() {
 			Execute:         port_scan.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params:          scanParams("IP address of the scanner. By default, it's the Censys IP"),
 		},
 	)
 	tasks.Registered.Register(
@@ -30,6 +31,7 @@ func init() {
 			Execute:         protocol_scan.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params:          scanParams("IP address of the scanner. By default, it's the Censys IP"),
 		},
 	)
 }
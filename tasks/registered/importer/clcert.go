@@ -22,6 +22,14 @@ func init() {
 			Execute:         chilean_dns.Execute,
 			NumSources:      2,
 			NumSavers:       1,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "onlyIPASN",
+					Type:        tasks.BoolParam,
+					Default:     "false",
+					Description: "If true, the scan is not imported and only the IP-ASN-Country relations are updated",
+				},
+			},
 		},
 		&tasks.Process{
 			Name:            "Fix accessible in Chilean DNS scan",
@@ -32,6 +40,18 @@ func init() {
 			Execute:         fix_accessible.Execute,
 			NumSources:      1,
 			NumSavers:       0,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "minDate",
+					Type:        tasks.DateParam,
+					Description: "First day of the scans to fix. By default, all the previous scans are fixed",
+				},
+				{
+					Name:        "maxDate",
+					Type:        tasks.DateParam,
+					Description: "Last day of the scans to fix. By default, it's tomorrow",
+				},
+			},
 		},
 		&tasks.Process{
 			Name:            "CLCERT Darknet",
@@ -42,6 +62,14 @@ func init() {
 			Execute:         darknet.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "numWorkers",
+					Type:        tasks.IntParam,
+					Default:     "1",
+					Description: "Number of files processed at the same time",
+				},
+			},
 		},
 		&tasks.Process{
 			Name:            "CLCERT Domain Categories Definition",
@@ -62,6 +90,14 @@ func init() {
 			Execute:         port_scan.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params: scanParams(
+				"IP address of the scanner. By default, it's the address of the source server",
+				&tasks.ParamSpec{
+					Name:        "port",
+					Type:        tasks.IntParam,
+					Description: "Port scanned. By default, it's parsed from the file name",
+				},
+			),
 		},
 		&tasks.Process{
 			Name:            "CLCERT Grabber Protocol Scan",
@@ -72,6 +108,15 @@ func init() {
 			Execute:         grabber_protocol_scan.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params: scanParams(
+				"IP address of the scanner. By default, it's the address of the source server",
+				&tasks.ParamSpec{
+					Name:        "certs_only",
+					Type:        tasks.BoolParam,
+					Default:     "false",
+					Description: "If true, only certificates are imported, skipping port scans",
+				},
+			),
 		},
 	)
 }
@@ -17,6 +17,13 @@ func init() {
 			DefaultSourceID: models.MaxMind,
 			NumSources:      1,
 			NumSavers:       1,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "blacklist",
+					Type:        tasks.ListParam,
+					Description: "Comma separated list of domains to ignore",
+				},
+			},
 		},
 	)
 }
@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"github.com/clcert/osr/tasks"
+)
+
+// scanDateLayout is the layout of the dates used to filter scans.
+const scanDateLayout = "20060102"
+
+// dateParams returns the params used to filter the imported data by date.
+func dateParams() tasks.ParamSchema {
+	return tasks.ParamSchema{
+		{
+			Name:        "since",
+			Type:        tasks.DateParam,
+			Layout:      scanDateLayout,
+			Description: "Ignores data dated before this day",
+		},
+		{
+			Name:        "until",
+			Type:        tasks.DateParam,
+			Layout:      scanDateLayout,
+			Description: "Ignores data dated after this day",
+		},
+	}
+}
+
+// scanParams returns the params used by the scan importers to filter the scanned data,
+// followed by the extra params specific to a process.
+func scanParams(srcIPDescription string, extra ...*tasks.ParamSpec) tasks.ParamSchema {
+	schema := tasks.ParamSchema{
+		{
+			Name:        "src_ip",
+			Type:        tasks.IPParam,
+			Description: srcIPDescription,
+		},
+		{
+			Name:        "blacklist",
+			Type:        tasks.IntListParam,
+			Description: "Comma separated list of ports to ignore",
+		},
+	}
+	schema = append(schema, dateParams()...)
+	return append(schema, extra...)
+}
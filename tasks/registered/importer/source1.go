@@ -17,6 +17,7 @@ func init() {
 			Execute:         reports.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params:          dateParams(),
 		},
 	)
 }
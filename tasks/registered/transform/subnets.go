@@ -27,6 +27,13 @@ func init() {
 			Execute:         delta_dates.Execute,
 			NumSources:      1,
 			NumSavers:       1,
+			Params: tasks.ParamSchema{
+				{
+					Name:        "export_name",
+					Type:        tasks.StringParam,
+					Description: "Name of the exported file. By default, it's based on the names of the compared files",
+				},
+			},
 		},
 	)
 }
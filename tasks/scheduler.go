@@ -0,0 +1,170 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/sirupsen/logrus"
+)
+
+// processResult is sent by a process goroutine when it finishes.
+type processResult struct {
+	index int   // Index of the process in the task
+	err   error // Error returned by the process, if any
+}
+
+// scheduler executes the processes of a task, running at most a fixed
+// number of them at the same time and respecting their dependencies.
+type scheduler struct {
+	task    *Task              // Task with the processes to execute
+	workers int                // Max number of processes executed at the same time
+	ctx     context.Context    // Context shared by all the processes of the task
+	cancel  context.CancelFunc // Cancels the processes still running
+}
+
+// newScheduler returns a new scheduler for a task. If workers is less than one,
+// the processes are executed one at a time.
+func newScheduler(task *Task, workers int) *scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(task.ctx)
+	return &scheduler{
+		task:    task,
+		workers: workers,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// run executes all the processes of the task and waits until they finish.
+// Processes are started in topological order, as soon as all their dependencies
+// succeeded. If a dependency failed or was skipped, the process is skipped.
+// It returns true if the execution was aborted, because the dependencies were invalid
+// or because a process failed and the task has AbortOnError set.
+func (s *scheduler) run() bool {
+	defer s.cancel()
+	order, err := s.task.topologicalOrder()
+	if err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"task": s.task.Name,
+		}).Errorf("Invalid process dependencies: %s", err)
+		return true
+	}
+	deps, _ := s.task.dependencies()
+	status := make([]models.ProcessStatus, len(s.task.Processes))
+	results := make(chan *processResult)
+	numRunning := 0
+	for {
+		if s.ctx.Err() == nil {
+			for _, index := range order {
+				if numRunning >= s.workers {
+					break
+				}
+				if status[index] != models.PENDING {
+					continue
+				}
+				switch s.check(deps[index], status) {
+				case models.SUCCEEDED:
+					if s.task.checkpoint.succeeded(index, s.task.Processes[index].Command) {
+						status[index] = models.SUCCEEDED
+						s.resume(index)
+						continue
+					}
+					status[index] = models.RUNNING
+					numRunning++
+					go s.execute(index, results)
+				case models.SKIPPED:
+					status[index] = models.SKIPPED
+					s.skip(index, deps[index], status)
+				}
+			}
+		}
+		if numRunning == 0 {
+			break
+		}
+		result := <-results
+		numRunning--
+		if result.err != nil {
+			status[result.index] = models.FAILED
+		} else {
+			status[result.index] = models.SUCCEEDED
+		}
+	}
+	return s.ctx.Err() != nil
+}
+
+// check returns the status a process should have, based on the status of its dependencies:
+// succeeded if it can be executed, skipped if it should be skipped and pending if it should wait.
+func (s *scheduler) check(deps []int, status []models.ProcessStatus) models.ProcessStatus {
+	result := models.SUCCEEDED
+	for _, dep := range deps {
+		switch status[dep] {
+		case models.FAILED, models.SKIPPED:
+			return models.SKIPPED
+		case models.SUCCEEDED:
+		default:
+			result = models.PENDING
+		}
+	}
+	return result
+}
+
+// execute executes a process and registers its result on the task.
+func (s *scheduler) execute(index int, results chan<- *processResult) {
+	process := s.task.Processes[index].Command
+	err := s.task.execute(s.ctx, process, index)
+	if err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"task":    s.task.Name,
+			"index":   index,
+			"process": process,
+		}).Errorf("Task failed: %s", err)
+		s.task.AddFailed(process, err)
+		if s.task.AbortOnError {
+			logs.Log.WithFields(logrus.Fields{
+				"task":    s.task.Name,
+				"index":   index,
+				"process": process,
+			}).Error("Aborting task and cancelling running processes...")
+			s.cancel()
+		}
+	} else {
+		s.task.AddSucceeded(process)
+	}
+	results <- &processResult{
+		index: index,
+		err:   err,
+	}
+}
+
+// resume registers as succeeded a process which succeeded on a previous execution of the task.
+func (s *scheduler) resume(index int) {
+	process := s.task.Processes[index].Command
+	logs.Log.WithFields(logrus.Fields{
+		"task":    s.task.Name,
+		"index":   index,
+		"process": process,
+	}).Info("Process succeeded on a previous execution, skipping it...")
+	s.task.AddSucceeded(process)
+}
+
+// skip registers a process as skipped on the task.
+func (s *scheduler) skip(index int, deps []int, status []models.ProcessStatus) {
+	process := s.task.Processes[index].Command
+	reasons := make([]string, 0)
+	for _, dep := range deps {
+		if status[dep] == models.FAILED || status[dep] == models.SKIPPED {
+			reasons = append(reasons, s.task.Processes[dep].Command)
+		}
+	}
+	logs.Log.WithFields(logrus.Fields{
+		"task":    s.task.Name,
+		"index":   index,
+		"process": process,
+		"because": reasons,
+	}).Warn("Skipping process because some of its dependencies didn't succeed")
+	s.task.AddSkipped(process)
+	s.task.checkpoint.setStatus(index, process, models.SKIPPED)
+}
@@ -0,0 +1,154 @@
+package tasks
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/sirupsen/logrus"
+)
+
+// newGraphConfig returns a task config with a process per command, depending on the commands of deps.
+func newGraphConfig(commands []string, deps map[int][]string) *TaskConfig {
+	config := &TaskConfig{
+		Name:      "test",
+		Processes: make([]*ProcessConfig, len(commands)),
+	}
+	for i, command := range commands {
+		config.Processes[i] = &ProcessConfig{
+			Command:   command,
+			DependsOn: deps[i],
+		}
+	}
+	return config
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		deps     map[int][]string
+		order    []int
+		err      string
+	}{
+		{
+			name:     "no dependencies keep the file order",
+			commands: []string{"a", "b", "c"},
+			order:    []int{0, 1, 2},
+		},
+		{
+			name:     "dependency defined after the process",
+			commands: []string{"b", "a"},
+			deps:     map[int][]string{0: {"a"}},
+			order:    []int{1, 0},
+		},
+		{
+			name:     "diamond",
+			commands: []string{"d", "b", "c", "a"},
+			deps:     map[int][]string{0: {"b", "c"}, 1: {"a"}, 2: {"a"}},
+			order:    []int{3, 1, 2, 0},
+		},
+		{
+			name:     "repeated command waits for all its instances",
+			commands: []string{"b", "a", "a"},
+			deps:     map[int][]string{0: {"a"}},
+			order:    []int{1, 2, 0},
+		},
+		{
+			name:     "unknown dependency",
+			commands: []string{"a", "b"},
+			deps:     map[int][]string{1: {"missing"}},
+			err:      "depends on missing, which is not defined in the task",
+		},
+		{
+			name:     "self dependency",
+			commands: []string{"a"},
+			deps:     map[int][]string{0: {"a"}},
+			err:      "depends on itself",
+		},
+		{
+			name:     "cycle of two processes",
+			commands: []string{"a", "b"},
+			deps:     map[int][]string{0: {"b"}, 1: {"a"}},
+			err:      "dependency cycle found",
+		},
+		{
+			name:     "cycle after valid processes",
+			commands: []string{"a", "b", "c", "d"},
+			deps:     map[int][]string{1: {"a", "d"}, 2: {"b"}, 3: {"c"}},
+			err:      "dependency cycle found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order, err := newGraphConfig(test.commands, test.deps).topologicalOrder()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(order, test.order) {
+				t.Errorf("expected order %v, got %v", test.order, order)
+			}
+		})
+	}
+}
+
+func TestSchedulerCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		status []models.ProcessStatus
+		result models.ProcessStatus
+	}{
+		{"no dependencies", []models.ProcessStatus{}, models.SUCCEEDED},
+		{"dependencies succeeded", []models.ProcessStatus{models.SUCCEEDED, models.SUCCEEDED}, models.SUCCEEDED},
+		{"dependency running", []models.ProcessStatus{models.SUCCEEDED, models.RUNNING}, models.PENDING},
+		{"dependency pending", []models.ProcessStatus{models.PENDING, models.SUCCEEDED}, models.PENDING},
+		{"dependency failed", []models.ProcessStatus{models.RUNNING, models.FAILED}, models.SKIPPED},
+		{"dependency skipped", []models.ProcessStatus{models.SKIPPED, models.SUCCEEDED}, models.SKIPPED},
+	}
+	s := &scheduler{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deps := make([]int, len(test.status))
+			for i := range deps {
+				deps[i] = i
+			}
+			if result := s.check(deps, test.status); result != test.result {
+				t.Errorf("expected %v, got %v", test.result, result)
+			}
+		})
+	}
+}
+
+func TestSchedulerAbortsOnInvalidDependencies(t *testing.T) {
+	logs.Log = &logs.OSRLog{Logger: logrus.New()}
+	tests := []struct {
+		name string
+		deps map[int][]string
+	}{
+		{"unknown dependency", map[int][]string{0: {"missing"}}},
+		{"cycle", map[int][]string{0: {"b"}, 1: {"a"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := &Task{
+				TaskConfig: newGraphConfig([]string{"a", "b"}, test.deps),
+				ctx:        context.Background(),
+			}
+			if !newScheduler(task, 1).run() {
+				t.Error("expected the execution to be aborted")
+			}
+			if len(task.Succeeded) > 0 || len(task.Failed) > 0 {
+				t.Errorf("expected no process to be executed, got %v succeeded and %v failed", task.Succeeded, task.Failed)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package tasks
 
 import (
+	"context"
 	"fmt"
-	"path"
 	"strings"
+	"sync"
 
 	"github.com/clcert/osr/databases"
 	"github.com/clcert/osr/logs"
@@ -14,38 +15,51 @@ import (
 	"github.com/clcert/osr/utils"
 	"github.com/go-pg/pg/v10"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
 // Defines a complete file with one or more process, savers and exports.
 type TaskConfig struct {
-	Name         string              // Name of the task
-	Description  string              // Description of the task
-	AbortOnError bool                // If true, task aborts if a process throws an error
-	Incognito    bool                // If true, task is not registered and taskID is assigned to 0
-	Parallel     bool                // Tasks are executed in parallel (?)
-	Params       utils.Params        // A list of global parameters
-	Processes    []*ProcessConfig    // A list of config for processes.
+	Name         string                     // Name of the task
+	Description  string                     // Description of the task
+	AbortOnError bool                       // If true, task aborts if a process throws an error
+	Incognito    bool                       // If true, task is not registered and taskID is assigned to 0
+	Parallel     bool                       // If true, processes are executed concurrently
+	Workers      int                        // Max number of processes executed at the same time if Parallel is true. If it's not positive, all of them are executed at once.
+	Params       utils.Params               // A list of global parameters
+	Include      []string                   // Task files whose params and named sources and savers are added to this one. Their processes are ignored.
+	Sources      map[string]*sources.Config // Named sources, which processes can reference by name
+	Savers       map[string]*savers.Config  // Named savers, which processes can reference by name
+	Processes    []*ProcessConfig           // A list of config for processes.
 }
 
 // A task defines the state of execution of a TaskConfig. It contains the stats of the execution.
 type Task struct {
 	*TaskConfig
-	TaskSession *models.Task     // The task model used on this session
-	Succeeded   []string         // A list with succeeded processes
-	Failed      map[string]error // A list with failed processes
-	Attachments []string         // A list with attachments created by processes
-	DB          *pg.DB           // A pointer to a DB writer.
-	CmdParams   utils.Params     // Params received by command line. They have the highest preference.
+	TaskSession *models.Task                   // The task model used on this session
+	Succeeded   []string                       // A list with succeeded processes
+	Failed      map[string]error               // A list with failed processes
+	Skipped     []string                       // A list with processes skipped because a dependency didn't succeed
+	Attachments []string                       // A list with attachments created by processes
+	SaverStats  map[string][]models.SaverStats // Objects saved and errored by the savers of each process, by outID
+	DB          *pg.DB                         // A pointer to a DB writer.
+	CmdParams   utils.Params                   // Params received by command line. They have the highest preference.
+	mutex       sync.Mutex                     // Protects the stats of the execution when processes are executed in parallel.
+	checkpoint  *checkpoint                    // Stores the state of the execution, allowing to resume it. It's nil on incognito tasks.
+	ctx         context.Context                // Context of the task. All the process contexts derive from it.
+	cancel      context.CancelFunc             // Cancels the task context.
 }
 
 // GetSucceeded formats the names of the succeeded process related to the tasks.
 func (task *Task) GetSucceeded() string {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	return strings.Join(task.Succeeded, ", ")
 }
 
 // GetFailed formats the names of the failed process related to the tasks.
 func (task *Task) GetFailed() string {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	failed := make([]string, len(task.Failed))
 	i := 0
 	for process, _ := range task.Failed {
@@ -55,8 +69,34 @@ func (task *Task) GetFailed() string {
 	return strings.Join(failed, ", ")
 }
 
+// GetSkipped formats the names of the skipped process related to the tasks.
+func (task *Task) GetSkipped() string {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	return strings.Join(task.Skipped, ", ")
+}
+
 // Returns a new Task based on a task config.
-func New(config *TaskConfig, params []string) (newTask *Task, err error) {
+func New(config *TaskConfig, params []string) (*Task, error) {
+	return newTask(config, params, 0)
+}
+
+// Resume returns a Task which continues the execution of a previous task session, reusing its ID.
+// The processes that succeeded on previous executions of the session are not executed again, and the
+// entries consumed by the other processes are skipped.
+func Resume(config *TaskConfig, params []string, taskID int) (*Task, error) {
+	if config.Incognito {
+		return nil, fmt.Errorf("incognito tasks cannot be resumed")
+	}
+	if taskID <= 0 {
+		return nil, fmt.Errorf("invalid task ID: %d", taskID)
+	}
+	return newTask(config, params, taskID)
+}
+
+// newTask returns a new Task based on a task config. If resumeID is positive, it resumes the
+// task session with that ID instead of creating a new one.
+func newTask(config *TaskConfig, params []string, resumeID int) (newTask *Task, err error) {
 	logs.Log.Info("Initializing Database Connection...")
 	dbHandler, err := databases.GetPostgresWriter()
 	if err != nil {
@@ -65,55 +105,124 @@ func New(config *TaskConfig, params []string) (newTask *Task, err error) {
 		}).Error("Couldn't connect to database")
 		return
 	}
-	currentTask, err := models.NewTaskSession(dbHandler, !config.Incognito)
+	var currentTask *models.Task
+	if resumeID > 0 {
+		currentTask, err = models.GetTask(dbHandler, resumeID)
+		if err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"error":   err,
+				"task_id": resumeID,
+			}).Error("Couldn't get task session to resume")
+			return
+		}
+		if err = currentTask.Resume(); err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"error":   err,
+				"task_id": resumeID,
+			}).Error("Couldn't resume task session")
+			return
+		}
+		err = currentTask.Save(dbHandler)
+	} else {
+		currentTask, err = models.NewTaskSession(dbHandler, !config.Incognito)
+	}
 	if err != nil {
 		logs.Log.WithFields(logrus.Fields{
 			"error": err,
 		}).Error("Couldn't initialize imports: cannot write to database")
 		return
 	}
+	var taskCheckpoint *checkpoint
+	if !config.Incognito {
+		taskCheckpoint, err = newCheckpoint(dbHandler, currentTask.ID, resumeID > 0)
+		if err != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"error":   err,
+				"task_id": currentTask.ID,
+			}).Error("Couldn't load task checkpoint")
+			return
+		}
+	}
 	cmdParams := utils.ListToParams(params)
+	ctx, cancel := context.WithCancel(context.Background())
 	newTask = &Task{
 		TaskConfig:  config,
 		TaskSession: currentTask,
 		DB:          dbHandler,
 		Succeeded:   make([]string, 0),
 		Failed:      make(map[string]error, 0),
+		Skipped:     make([]string, 0),
 		Attachments: make([]string, 0),
+		SaverStats:  make(map[string][]models.SaverStats),
 		CmdParams:   cmdParams,
+		checkpoint:  taskCheckpoint,
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 	logs.Log.WithFields(logrus.Fields{
 		"importer":  newTask.TaskSession.ID,
 		"incognito": config.Incognito,
+		"resumed":   resumeID > 0,
 	}).Info("Created new task session!")
 	return
 }
 
-// ExecuteAll executes a entire task.
+// Execute executes a entire task. If the task is parallel, its processes are executed
+// concurrently by a scheduler, limited by the Workers value of the task.
 func (task *Task) Execute() {
-	defer notify(task)
-	processes := task.GetCommands()
-	for index, process := range processes {
-		err := task.execute(process, index)
-		if err != nil {
+	task.ExecuteContext(context.Background())
+}
+
+// ExecuteContext executes a entire task like Execute, but cancels it if ctx is done
+// before the task finishes. In that case, the task session is marked as failed.
+func (task *Task) ExecuteContext(ctx context.Context) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
 			logs.Log.WithFields(logrus.Fields{
-				"task": task.Name,
-				"index": index,
-				"process": process,
-			}).Errorf("Task failed: %s", err)
-			task.AddFailed(process, err)
-			if task.AbortOnError {
-				task.TaskSession.Failed()
-				return
+				"task_id": task.TaskSession.ID,
+			}).Warn("Cancelling task...")
+			task.Cancel()
+		case <-done:
+		}
+	}()
+	defer notify(task)
+	workers := 1
+	if task.Parallel {
+		workers = task.Workers
+		if workers <= 0 || workers > len(task.Processes) {
+			workers = len(task.Processes)
+		}
+	}
+	logs.Log.WithFields(logrus.Fields{
+		"task":     task.Name,
+		"parallel": task.Parallel,
+		"workers":  workers,
+	}).Info("Executing task processes...")
+	if aborted := newScheduler(task, workers).run(); aborted {
+		logs.Log.WithFields(logrus.Fields{
+			"task_id":   task.TaskSession.ID,
+			"succeeded": task.GetSucceeded(),
+			"failed":    task.GetFailed(),
+			"skipped":   task.GetSkipped(),
+		}).Error("Task aborted")
+		task.TaskSession.Failed()
+		if !task.Incognito {
+			if err := task.TaskSession.Save(task.DB); err != nil {
+				logs.Log.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("Couldn't save import as failed in database")
 			}
-		} else {
-			task.AddSucceeded(process)
 		}
+		return
 	}
 	logs.Log.WithFields(logrus.Fields{
 		"task_id":   task.TaskSession.ID,
 		"succeeded": task.GetSucceeded(),
 		"failed":    task.GetFailed(),
+		"skipped":   task.GetSkipped(),
 	}).Info("All importer functions executed")
 	task.TaskSession.Succeeded()
 	if !task.Incognito {
@@ -133,8 +242,20 @@ func (task *Task) Execute() {
 	return
 }
 
-// Export executes a specific process name in a task
-func (task *Task) execute(processName string, processIndex int) error {
+// Cancel cancels the execution of the task. The running processes are
+// notified through their context, and the processes not started yet are not executed.
+func (task *Task) Cancel() {
+	task.cancel()
+}
+
+// execute executes a specific process name in a task. The context is
+// cancelled if the task is aborted or if the process exceeds its timeout.
+func (task *Task) execute(ctx context.Context, processName string, processIndex int) (err error) {
+	record := task.checkpoint.start(processIndex, processName)
+	var args *Context
+	defer func() {
+		task.checkpoint.finish(record, args, err)
+	}()
 	config := task.GetConfig(processIndex)
 	if config == nil {
 		logs.Log.WithFields(logrus.Fields{
@@ -143,6 +264,11 @@ func (task *Task) execute(processName string, processIndex int) error {
 		}).Error("Process not found on task")
 		return fmt.Errorf("process config not found on task: %s", processName)
 	}
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
 
 	process, ok := Registered[processName]
 	if !ok {
@@ -157,21 +283,33 @@ func (task *Task) execute(processName string, processIndex int) error {
 	}
 
 	// Add args
-	args, err := process.NewArgs(task, processIndex)
+	args, err = process.NewArgs(task, processIndex)
 	if err != nil {
 		return err
 	}
+	args.Ctx = ctx
 
 	// Add process specific params
 	args.Params = args.Params.Join(config.Params)
 	// Add command line specific params
 	args.Params = args.Params.Join(task.CmdParams)
+	// Check params and add default values
+	args.Params, err = process.Params.Validate(args.Params)
+	if err != nil {
+		logs.Log.WithFields(logrus.Fields{
+			"command": processName,
+			"index": processIndex,
+		}).Errorf("Invalid process params: %s", err)
+		return err
+	}
 
 	// parse and initialize sourcesList
 	if process.NumSources >= 0 && len(config.Sources) != process.NumSources {
 		return fmt.Errorf("there should be only %d sources(s) with this process and there are %d", process.NumSources, len(config.Sources))
 	}
 	sourcesList := make([]sources.Source, 0)
+	committedSources := make([]sources.Source, 0)
+	incrementalSources := make([]*incrementalSource, 0)
 	for i, sourceConf := range config.Sources {
 		sourceName := fmt.Sprintf("%s_%s_source-%d", task.GetSafeName(), process.GetSafeName(), i)
 		source, err := sourceConf.New(sourceName, args.Params)
@@ -179,11 +317,17 @@ func (task *Task) execute(processName string, processIndex int) error {
 			logs.Log.WithFields(logrus.Fields{
 				"command": processName,
 				"index": processIndex,
-			}).Error("source list error on index %d: %s", i, err)
+			}).Errorf("source list error on index %d: %s", i, err)
 			return fmt.Errorf("source list error on index %d: %s", i, err)
 		}
-		sourcesList = append(sourcesList, source)
-		if err := source.Init(); err != nil {
+		committedSources = append(committedSources, source)
+		if sourceConf.Incremental {
+			incremental := newIncrementalSource(task.DB, task.TaskSession.ID, source)
+			incrementalSources = append(incrementalSources, incremental)
+			source = incremental
+		}
+		sourcesList = append(sourcesList, task.checkpoint.wrap(ctx, source, processIndex, i))
+		if err := source.Init(ctx); err != nil {
 			return fmt.Errorf("error initializing source: %s", err)
 		}
 		task.AddAttachments(source)
@@ -196,11 +340,60 @@ func (task *Task) execute(processName string, processIndex int) error {
 		}
 	}()
 
+	// Consumed entries are saved after the savers finish, so their data is already stored. If the process failed,
+	// they are saved only if the savers stored all the objects they received. Otherwise (e.g. atomic savers discarded
+	// their data), the entries will be consumed again when the task is resumed.
+	discarded := false
+	defer func() {
+		if err != nil && (discarded || saversHadErrors(args.Savers)) {
+			return
+		}
+		for _, source := range args.Sources {
+			task.checkpoint.save(source)
+		}
+	}()
+
+	// The state of incremental sources is saved only if the process succeeded.
+	// Incognito tasks use it, but they don't change it.
+	defer func() {
+		if err != nil || task.Incognito {
+			return
+		}
+		for _, source := range incrementalSources {
+			source.save()
+		}
+	}()
+
+	// The sources which keep state of the consumed entries (e.g. HTTP caches or local done folders) also save it only if the process succeeded,
+	// so the entries are consumed again on the next execution if it failed.
+	defer func() {
+		if err != nil {
+			return
+		}
+		for _, source := range committedSources {
+			if commitErr := sources.Commit(source); commitErr != nil {
+				logs.Log.WithFields(logrus.Fields{
+					"command": processName,
+					"index":   processIndex,
+					"source":  source.GetName(),
+				}).Errorf("Couldn't save source state: %s", commitErr)
+			}
+		}
+	}()
+
 	// parse and initialize saversList
 	if process.NumSavers >= 0 && len(config.Savers) != process.NumSavers {
 		return fmt.Errorf("there should be only %d saver(s) with this process and there are %d", process.NumSavers, len(config.Savers))
 	}
 	saversList := make([]savers.Saver, 0)
+
+	// Atomic savers are committed only if the process succeeded, so the process fails if they cannot commit.
+	// The savers are finished even if a later saver cannot be started, so the started ones are rolled back.
+	defer func() {
+		discarded, err = finishSavers(saversList, config.SaverErrorThreshold, err)
+		task.AddSaverStats(processName, getSaverStats(saversList))
+	}()
+
 	for i, saverConf := range config.Savers {
 		saverName := fmt.Sprintf("%s_%s_saver_%d", task.GetSafeName(), process.GetSafeName(), i)
 		saver, err := saverConf.New(saverName, args.Params)
@@ -211,29 +404,26 @@ func (task *Task) execute(processName string, processIndex int) error {
 			}).Errorf("saver list error on index %d: %s", i, err)
 			return fmt.Errorf("saver list error on index %d: %s", i, err)
 		}
-		saversList = append(saversList, saver)
-		if err := saver.Start(); err != nil {
+		if err := saver.Start(ctx); err != nil {
 			logs.Log.WithFields(logrus.Fields{
 				"command": processName,
 				"index": processIndex,
 			}).Errorf("Cannot start saver with index %d: %s", i, err)
 			return err
 		}
+		saversList = append(saversList, saver)
 		task.AddAttachments(saver)
 	}
 	args.AddSavers(saversList)
 
-	defer func() {
-		for _, saver := range args.Savers {
-			_ = saver.Finish()
-		}
-	}()
-
 	logs.Log.WithFields(logrus.Fields{
 		"command": processName,
 		"index": processIndex,
 	}).Info("executing process")
 	err = process.Execute(args)
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("process cancelled: %s", ctx.Err())
+	}
 	if err == nil {
 		logs.Log.WithFields(logrus.Fields{
 			"command": processName,
@@ -243,11 +433,103 @@ func (task *Task) execute(processName string, processIndex int) error {
 		logs.Log.WithFields(logrus.Fields{
 			"command": processName,
 			"index": processIndex,
-		}).Error("process failed")
+		}).Errorf("process failed: %s", err)
 	}
 	return err
 }
 
+// finishSavers finishes the savers of a process and collects their errors. The process fails if an atomic saver
+// had errors, or if the savers couldn't save at least threshold objects and threshold is positive.
+// The atomic savers are committed if the process succeeded, and rolled back if not. It returns true if the objects
+// of atomic savers were discarded, and the error of the process, or the error which made it fail.
+// If there are many atomic savers and one of them cannot commit, the ones committed before it keep their objects.
+func finishSavers(saverList []savers.Saver, threshold int, err error) (bool, error) {
+	atomicSavers := make([]savers.AtomicSaver, 0)
+	var erroredObjects int
+	var firstError error
+	for _, saver := range saverList {
+		finishErr := saver.Finish()
+		saverErrors := saver.GetErrors()
+		if finishErr != nil && len(saverErrors) == 0 {
+			saverErrors = []error{finishErr}
+		}
+		if len(saverErrors) > 0 {
+			logs.Log.WithFields(logrus.Fields{
+				"saver":  saver.GetName(),
+				"errors": len(saverErrors),
+			}).Warnf("Saver finished with errors, the first one was: %s", saverErrors[0])
+			if firstError == nil {
+				firstError = saverErrors[0]
+			}
+		}
+		erroredObjects += countErroredObjects(saver)
+		atomicSaver, ok := saver.(savers.AtomicSaver)
+		if !ok || !atomicSaver.IsAtomic() {
+			continue
+		}
+		atomicSavers = append(atomicSavers, atomicSaver)
+		if err != nil {
+			continue
+		}
+		if finishErr != nil {
+			err = fmt.Errorf("cannot finish atomic saver %s: %s", saver.GetName(), finishErr)
+		} else if len(saverErrors) > 0 {
+			err = fmt.Errorf("atomic saver %s had %d errors, the first one was: %s", saver.GetName(), len(saverErrors), saverErrors[0])
+		}
+	}
+	if err == nil && threshold > 0 && erroredObjects >= threshold {
+		err = fmt.Errorf("savers couldn't save %d objects (threshold: %d), the first error was: %s", erroredObjects, threshold, firstError)
+	}
+	discarded := false
+	for _, atomicSaver := range atomicSavers {
+		if err == nil {
+			if commitErr := atomicSaver.Commit(); commitErr != nil {
+				err = fmt.Errorf("cannot commit atomic saver %s: %s", atomicSaver.GetName(), commitErr)
+				discarded = true
+			}
+			continue
+		}
+		logs.Log.WithFields(logrus.Fields{
+			"saver": atomicSaver.GetName(),
+		}).Warnf("Discarding objects of atomic saver: %s", err)
+		if rollbackErr := atomicSaver.Rollback(); rollbackErr != nil {
+			logs.Log.WithFields(logrus.Fields{
+				"saver": atomicSaver.GetName(),
+			}).Errorf("Cannot rollback atomic saver: %s", rollbackErr)
+		}
+		discarded = true
+	}
+	return discarded, err
+}
+
+// saversHadErrors returns true if a saver of the list couldn't save some of the objects it received.
+func saversHadErrors(saverList []savers.Saver) bool {
+	for _, saver := range saverList {
+		if len(saver.GetErrors()) > 0 || countErroredObjects(saver) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// countErroredObjects returns the number of objects a saver couldn't save, adding the errors of all its outIDs.
+func countErroredObjects(saver savers.Saver) int {
+	errored := 0
+	for _, outIDStats := range saver.GetStats() {
+		errored += outIDStats.Errors
+	}
+	return errored
+}
+
+// getSaverStats returns the objects saved and errored by each saver of a list, by outID.
+func getSaverStats(saverList []savers.Saver) []models.SaverStats {
+	stats := make([]models.SaverStats, len(saverList))
+	for i, saver := range saverList {
+		stats[i] = saver.GetStats()
+	}
+	return stats
+}
+
 // Close closes the task database.
 func (task *Task) Close() error {
 	return task.DB.Close()
@@ -255,19 +537,52 @@ func (task *Task) Close() error {
 
 // HasErrors returns true if the task had any errors on its execution.
 func (task *Task) HasErrors() bool {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	return len(task.Failed) > 0
 }
 
 // AddSucceeded adds a name to succeeded list
 func (task *Task) AddSucceeded(name string) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	task.Succeeded = append(task.Succeeded, name)
 }
 
+// AddSkipped adds a name to skipped list
+func (task *Task) AddSkipped(name string) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	task.Skipped = append(task.Skipped, name)
+}
+
 // AddFailed adds an error to failed list
 func (task *Task) AddFailed(name string, err error) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	task.Failed[name] = err
 }
 
+// AddSaverStats adds the stats of the savers of a process
+func (task *Task) AddSaverStats(name string, stats []models.SaverStats) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	task.SaverStats[name] = stats
+}
+
+// GetSaverStats returns the stats of the savers of the processes which have savers.
+func (task *Task) GetSaverStats() map[string][]models.SaverStats {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	stats := make(map[string][]models.SaverStats, len(task.SaverStats))
+	for name, saverStats := range task.SaverStats {
+		if len(saverStats) > 0 {
+			stats[name] = saverStats
+		}
+	}
+	return stats
+}
+
 // GetConfig returns the configuration with an specific name in the task.
 func (task *Task) GetConfig(index int) *ProcessConfig {
 	if index < len(task.TaskConfig.Processes) {
@@ -287,35 +602,47 @@ func (task *Task) GetCommands() []string {
 
 // AddAttachments adds an attachment to the task state.
 func (task *Task) AddAttachments(attachable mailer.Attachable) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	for _, attachment := range attachable.GetAttachments() {
 		task.Attachments = append(task.Attachments, attachment)
 	}
 }
 
 func (task *Task) GetAttachments() []string {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
 	return task.Attachments
 }
 
 // ParseConfig parses the config related to the task from a specific file.
 func ParseConfig(name string) (*TaskConfig, error) {
-	viperConfig := viper.New()
-	taskRoot, err := GetTasksPath()
+	config, err := loadConfig(name, nil)
 	if err != nil {
 		return nil, err
 	}
-	viperConfig.SetConfigFile(path.Join(taskRoot, name))
-	if err := viperConfig.ReadInConfig(); err != nil {
+	if err := config.resolveRefs(); err != nil {
 		return nil, err
 	}
-	var config TaskConfig
-
-	if err := viperConfig.Unmarshal(&config); err != nil {
+	if _, err := config.topologicalOrder(); err != nil {
 		return nil, err
 	}
-	return &config, nil
+	return config, nil
 }
 
 // GetSafeName returns the safe name for the task.
 func (task *Task) GetSafeName() string {
-	return fmt.Sprintf("task-%d", task.TaskSession.ID)
+	return safeName(task.TaskSession.ID)
+}
+
+// safeName returns the safe name for a task session ID.
+// It's used as prefix of the names of the logs created by the session.
+func safeName(taskID int) string {
+	return fmt.Sprintf("task-%d", taskID)
+}
+
+// GetLogPaths returns the paths of the log files created by a task session
+// and its processes, sources and savers.
+func GetLogPaths(taskID int) ([]string, error) {
+	return logs.FindLogs("_" + safeName(taskID) + "_")
 }
@@ -0,0 +1,64 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/clcert/osr/logs"
+	"github.com/clcert/osr/models"
+	"github.com/clcert/osr/savers"
+	"github.com/sirupsen/logrus"
+)
+
+func TestFinishSaversThreshold(t *testing.T) {
+	logs.Log = &logs.OSRLog{Logger: logrus.New()}
+	tests := []struct {
+		name      string
+		threshold int
+		savers    []*testSaver
+		fails     bool
+	}{
+		{
+			name:      "errors of every outID are added",
+			threshold: 3,
+			savers: []*testSaver{
+				{errors: []error{fmt.Errorf("duplicated key")}, stats: models.SaverStats{"a": {Inserted: 5, Errors: 1}, "b": {Errors: 1}}},
+				{stats: models.SaverStats{"c": {Inserted: 5, Errors: 1}}},
+			},
+			fails: true,
+		},
+		{
+			name:      "less errored objects than the threshold",
+			threshold: 3,
+			savers: []*testSaver{
+				{errors: []error{fmt.Errorf("a"), fmt.Errorf("b"), fmt.Errorf("c")}, stats: models.SaverStats{"a": {Inserted: 5, Errors: 2}}},
+			},
+			fails: false,
+		},
+		{
+			name:      "no threshold",
+			threshold: 0,
+			savers: []*testSaver{
+				{stats: models.SaverStats{"a": {Errors: 100}}},
+			},
+			fails: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			saverList := make([]savers.Saver, len(test.savers))
+			for i, saver := range test.savers {
+				saverList[i] = saver
+			}
+			discarded, err := finishSavers(saverList, test.threshold, nil)
+			if discarded {
+				t.Error("expected no discarded objects without atomic savers")
+			}
+			if test.fails && err == nil {
+				t.Error("expected the process to fail")
+			} else if !test.fails && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package tasks
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/clcert/osr/remote"
+	"github.com/clcert/osr/utils"
+)
+
+// ValidationError describes a problem found on a task config when validating it.
+type ValidationError struct {
+	Index   int    // Index of the process with the problem, or -1 if the problem is in the task
+	Command string // Command of the process with the problem
+	Field   string // Path of the config field with the problem, if any
+	Err     error  // The problem
+}
+
+func (e *ValidationError) Error() string {
+	location := "task"
+	if e.Index >= 0 {
+		location = fmt.Sprintf("process %d (%s)", e.Index, e.Command)
+	}
+	if e.Field != "" {
+		location += " " + e.Field
+	}
+	return fmt.Sprintf("%s: %s", location, e.Err)
+}
+
+// Validate checks a task config without executing it, returning the list of problems found.
+// It checks that the commands are registered, the number of sources and savers and the params of each process,
+// that the templates on the config of the sources and savers only use defined params and that the
+// servers they use are defined on the OSR config. It doesn't connect to databases nor servers.
+// The command line params are used as when the task is executed.
+func (config *TaskConfig) Validate(cmdParams []string) []error {
+	errs := make([]error, 0)
+	if len(config.Processes) == 0 {
+		errs = append(errs, &ValidationError{Index: -1, Err: fmt.Errorf("there are no processes defined")})
+	}
+	if _, err := config.topologicalOrder(); err != nil {
+		errs = append(errs, &ValidationError{Index: -1, Err: err})
+	}
+	servers := make(map[string]error)
+	for i, processConfig := range config.Processes {
+		addErr := func(field string, err error) {
+			errs = append(errs, &ValidationError{
+				Index:   i,
+				Command: processConfig.Command,
+				Field:   field,
+				Err:     err,
+			})
+		}
+		process, ok := Registered[processConfig.Command]
+		if !ok {
+			addErr("", fmt.Errorf("process not defined in system"))
+		} else {
+			if process.NumSources >= 0 && len(processConfig.Sources) != process.NumSources {
+				addErr("sources", fmt.Errorf("there should be %d source(s) and there are %d", process.NumSources, len(processConfig.Sources)))
+			}
+			if process.NumSavers >= 0 && len(processConfig.Savers) != process.NumSavers {
+				addErr("savers", fmt.Errorf("there should be %d saver(s) and there are %d", process.NumSavers, len(processConfig.Savers)))
+			}
+		}
+		params := config.Params.Join(processConfig.Params).Join(utils.ListToParams(cmdParams))
+		if ok {
+			if validated, err := process.Params.Validate(params); err != nil {
+				addErr("params", err)
+			} else {
+				params = validated
+			}
+		}
+		v := &configValidator{
+			params:  params,
+			servers: servers,
+			addErr:  addErr,
+		}
+		for j, sourceConfig := range processConfig.Sources {
+			v.check(fmt.Sprintf("sources[%d]", j), reflect.ValueOf(sourceConfig))
+		}
+		for j, saverConfig := range processConfig.Savers {
+			v.check(fmt.Sprintf("savers[%d]", j), reflect.ValueOf(saverConfig))
+		}
+	}
+	return errs
+}
+
+// configValidator walks the config of sources and savers, checking their templates and server names.
+type configValidator struct {
+	params  utils.Params                  // Params available to the process
+	servers map[string]error              // Cache of server names already resolved
+	addErr  func(field string, err error) // Registers a problem found
+}
+
+// check validates a config value and its children. Field is the path of the value in the process config.
+func (v *configValidator) check(field string, value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			v.check(field, value.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			fieldType := value.Type().Field(i)
+			if fieldType.PkgPath != "" {
+				continue // unexported
+			}
+			name := fieldType.Name
+			if fieldType.Anonymous {
+				v.check(field, value.Field(i))
+				continue
+			}
+			v.check(field+"."+name, value.Field(i))
+			if name == "ServerName" && value.Field(i).Kind() == reflect.String {
+				v.checkServer(field+"."+name, value.Field(i).String(), strings.HasPrefix(value.Type().Name(), "S3"))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			v.check(fmt.Sprintf("%s[%d]", field, i), value.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			keyField := fmt.Sprintf("%s[%v]", field, key)
+			v.check(keyField, key)
+			v.check(keyField, value.MapIndex(key))
+		}
+	case reflect.String:
+		if err := v.params.CheckString(value.String()); err != nil {
+			v.addErr(field, fmt.Errorf("cannot render template: %s", err))
+		}
+	}
+}
+
+// checkServer checks that a server name, after being formatted with the params, is defined on the OSR config.
+// If s3 is true, the name is looked up in the S3 storages instead of the remote servers.
+func (v *configValidator) checkServer(field, serverName string, s3 bool) {
+	if serverName == "" {
+		return
+	}
+	name := v.params.FormatString(serverName)
+	key := name
+	if s3 {
+		key = "s3:" + name
+	}
+	err, ok := v.servers[key]
+	if !ok {
+		if s3 {
+			_, err = remote.GetS3Server(name)
+		} else {
+			_, err = remote.GetServers(name)
+		}
+		v.servers[key] = err
+	}
+	if err != nil {
+		v.addErr(field, fmt.Errorf("server %s: %s", name, err))
+	}
+}
@@ -65,6 +65,16 @@ func (params Params) FormatString(str string) string {
 	return buf.String()
 }
 
+// CheckString returns an error if the string is not a valid template or if it uses
+// params which are not defined. It doesn't return the formatted string.
+func (params Params) CheckString(str string) error {
+	tmpl, err := template.New(GenerateRandomHex(6)).Option("missingkey=error").Parse(str)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(ioutil.Discard, NewFormatArgs(params))
+}
+
 // FormatStringMapString formats a map with string keys and string values, and returns
 // a formatted map of the same types.
 func (params Params) FormatStringMapString(m map[string]string) map[string]string {